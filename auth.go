@@ -0,0 +1,211 @@
+package rpc
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 在按方法的参数类型转换参数之前调用，返回调用方的身份，出错时调用会以CodeUnauthenticated失败
+type Authenticator interface {
+	Authenticate(ctx context.Context, call *CallInfo) (principal string, err error)
+}
+
+type AuthenticatorFunc func(ctx context.Context, call *CallInfo) (string, error)
+
+func (f AuthenticatorFunc) Authenticate(ctx context.Context, call *CallInfo) (string, error) {
+	return f(ctx, call)
+}
+
+type principalKey struct{}
+
+// 服务端的方法里拿到Authenticator返回的身份
+func PrincipalFromContext(ctx context.Context) (string, bool) {
+	principal, ok := ctx.Value(principalKey{}).(string)
+	return principal, ok
+}
+
+func (s *Server) authenticate(next Handler) Handler {
+	return func(ctx context.Context, call *CallInfo) ([]any, error) {
+		principal, err := s.opts.authenticator.Authenticate(ctx, call)
+		if err != nil {
+			var e *Error
+			if errors.As(err, &e) {
+				return nil, err
+			}
+			return nil, &Error{CodeUnauthenticated, err.Error()}
+		}
+		return next(context.WithValue(ctx, principalKey{}, principal), call)
+	}
+}
+
+// 从metadata的authorization里取"Bearer <token>"，交给validate校验
+func BearerAuth(validate func(ctx context.Context, token string) (principal string, err error)) Authenticator {
+	return AuthenticatorFunc(func(ctx context.Context, call *CallInfo) (string, error) {
+		token, ok := strings.CutPrefix(call.Metadata.Get("authorization"), "Bearer ")
+		if !ok || token == "" {
+			return "", errors.New("没有token")
+		}
+		return validate(ctx, token)
+	})
+}
+
+// 固定的token，tokens是token到身份的映射
+func BearerTokens(tokens map[string]string) Authenticator {
+	return BearerAuth(func(ctx context.Context, token string) (string, error) {
+		for t, principal := range tokens {
+			if hmac.Equal([]byte(t), []byte(token)) {
+				return principal, nil
+			}
+		}
+		return "", errors.New("token不对")
+	})
+}
+
+// 客户端的每次调用都带上token
+func WithBearerToken(token string) DialOption {
+	return WithInterceptor(func(next Invoker) Invoker {
+		return func(ctx context.Context, call *CallInfo) (*Out, error) {
+			call.Metadata["authorization"] = []string{"Bearer " + token}
+			return next(ctx, call)
+		}
+	})
+}
+
+const (
+	hmacKeyID     = "x-rpc-key-id"
+	hmacTimestamp = "x-rpc-timestamp"
+	hmacNonce     = "x-rpc-nonce"
+	hmacSignature = "x-rpc-signature"
+)
+
+// 签名内容是服务名、方法名、时间戳、nonce和参数的json，用换行分开
+// 参数先经过一次json编解码，客户端和服务端看到的数字才一样
+func hmacSign(secret []byte, call *CallInfo, timestamp, nonce string) (string, error) {
+	b, err := json.Marshal(call.InArgs)
+	if err != nil {
+		return "", err
+	}
+	var args []any
+	if err := json.Unmarshal(b, &args); err != nil {
+		return "", err
+	}
+	if b, err = json.Marshal(args); err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(call.ServiceName + "\n" + call.MethodName + "\n" + timestamp + "\n" + nonce + "\n"))
+	mac.Write(b)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// 客户端用keyID对应的secret给每次调用签名
+// 签名在所有拦截器之后，重试的每次尝试都重新签名，不会因为nonce重复被拒绝
+func WithHMACSigner(keyID string, secret []byte) DialOption {
+	return func(o *dialOptions) {
+		o.signer = func(call *CallInfo, md Metadata) error {
+			nonce := make([]byte, 16)
+			if _, err := rand.Read(nonce); err != nil {
+				return err
+			}
+			timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+			signature, err := hmacSign(secret, call, timestamp, hex.EncodeToString(nonce))
+			if err != nil {
+				return err
+			}
+			md[hmacKeyID] = []string{keyID}
+			md[hmacTimestamp] = []string{timestamp}
+			md[hmacNonce] = []string{hex.EncodeToString(nonce)}
+			md[hmacSignature] = []string{signature}
+			return nil
+		}
+	}
+}
+
+// keys是keyID到secret的映射，身份就是keyID
+// 时间戳和服务端相差超过window的请求会被拒绝，window内同一个nonce只能用一次
+func HMACAuth(keys map[string][]byte, window time.Duration) Authenticator {
+	return &hmacAuth{
+		keys:   keys,
+		window: window,
+		nonces: make(map[string]time.Time),
+		now:    time.Now,
+	}
+}
+
+type hmacAuth struct {
+	keys   map[string][]byte
+	window time.Duration
+	now    func() time.Time
+
+	mu     sync.Mutex
+	nonces map[string]time.Time
+	// 按记录的顺序排，也就是按过期时间排
+	expiry []nonceExpiry
+}
+
+type nonceExpiry struct {
+	nonce  string
+	expire time.Time
+}
+
+func (a *hmacAuth) Authenticate(ctx context.Context, call *CallInfo) (string, error) {
+	keyID := call.Metadata.Get(hmacKeyID)
+	timestamp := call.Metadata.Get(hmacTimestamp)
+	nonce := call.Metadata.Get(hmacNonce)
+	signature := call.Metadata.Get(hmacSignature)
+	if keyID == "" || timestamp == "" || nonce == "" || signature == "" {
+		return "", errors.New("没有签名")
+	}
+
+	secret, ok := a.keys[keyID]
+	if !ok {
+		return "", errors.New("key不存在")
+	}
+	want, err := hmacSign(secret, call, timestamp, nonce)
+	if err != nil || !hmac.Equal([]byte(want), []byte(signature)) {
+		return "", errors.New("签名不对")
+	}
+
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", errors.New("时间戳不对")
+	}
+	now := a.now()
+	if d := now.Sub(time.Unix(sec, 0)); d > a.window || d < -a.window {
+		return "", errors.New("请求过期")
+	}
+	if !a.useNonce(keyID+":"+nonce, now) {
+		return "", errors.New("重复的请求")
+	}
+	return keyID, nil
+}
+
+// 过期的nonce在记录新nonce的时候从队列头上清掉，不用扫整个map
+func (a *hmacAuth) useNonce(nonce string, now time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	i := 0
+	for ; i < len(a.expiry) && now.After(a.expiry[i].expire); i++ {
+		delete(a.nonces, a.expiry[i].nonce)
+	}
+	a.expiry = a.expiry[i:]
+
+	if _, ok := a.nonces[nonce]; ok {
+		return false
+	}
+	// 时间戳最多可以提前window，所以要记2倍window
+	expire := now.Add(2 * a.window)
+	a.nonces[nonce] = expire
+	a.expiry = append(a.expiry, nonceExpiry{nonce, expire})
+	return true
+}
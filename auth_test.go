@@ -0,0 +1,280 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type Secure struct{}
+
+func (s *Secure) WhoAmI(ctx context.Context) string {
+	principal, _ := PrincipalFromContext(ctx)
+	return principal
+}
+
+func (s *Secure) Add(a, b int) int {
+	return a + b
+}
+
+func TestBearerAuth(t *testing.T) {
+	server := NewServer(WithAuthenticator(BearerTokens(map[string]string{"t0ken": "guobin"})))
+	server.Register(new(Secure), "Secure")
+
+	client := pipeClient(server, WithBearerToken("t0ken"))
+	defer client.Close()
+	who, err := Call1[string](context.Background(), client, "Secure", "WhoAmI")
+	if err != nil || who != "guobin" {
+		t.Error(who, err)
+	}
+
+	for _, opts := range [][]DialOption{nil, {WithBearerToken("wrong")}} {
+		client := pipeClient(server, opts...)
+		_, err := client.Call("Secure", "WhoAmI", []interface{}{})
+		if ErrorCode(err) != CodeUnauthenticated {
+			t.Error(err)
+		}
+		// 认证在参数检查之前
+		_, err = client.Call("Secure", "Add", []interface{}{"a"})
+		if ErrorCode(err) != CodeUnauthenticated {
+			t.Error(err)
+		}
+		client.Close()
+	}
+}
+
+func TestBearerAuthHTTP(t *testing.T) {
+	server := NewServer(WithAuthenticator(BearerTokens(map[string]string{"t0ken": "guobin"})))
+	server.Register(new(Secure), "Secure")
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/rpc/Secure/WhoAmI", strings.NewReader("[]"))
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Error(res.Status)
+	}
+
+	req, _ = http.NewRequest(http.MethodPost, ts.URL+"/rpc/Secure/WhoAmI", strings.NewReader("[]"))
+	req.Header.Set("Authorization", "Bearer t0ken")
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Error(res.Status)
+	}
+}
+
+func TestAuthenticatorError(t *testing.T) {
+	server := NewServer(WithAuthenticator(AuthenticatorFunc(func(ctx context.Context, call *CallInfo) (string, error) {
+		if call.MethodName == "Add" {
+			return "", &Error{Code: CodeUnavailable, Message: "认证服务不可用"}
+		}
+		return "", errors.New("不认识")
+	})))
+	server.Register(new(Secure), "Secure")
+	client := pipeClient(server)
+	defer client.Close()
+
+	if _, err := client.Call("Secure", "Add", []interface{}{1, 2}); ErrorCode(err) != CodeUnavailable {
+		t.Error(err)
+	}
+	if _, err := client.Call("Secure", "WhoAmI", []interface{}{}); ErrorCode(err) != CodeUnauthenticated || err.Error() != "不认识" {
+		t.Error(err)
+	}
+}
+
+func TestHMACAuth(t *testing.T) {
+	auth := HMACAuth(map[string][]byte{"app1": []byte("secret")}, time.Minute).(*hmacAuth)
+	server := NewServer(WithAuthenticator(auth))
+	server.Register(new(Secure), "Secure")
+
+	client := pipeClient(server, WithHMACSigner("app1", []byte("secret")))
+	defer client.Close()
+	who, err := Call1[string](context.Background(), client, "Secure", "WhoAmI")
+	if err != nil || who != "app1" {
+		t.Error(who, err)
+	}
+	sum, err := Call1[int](context.Background(), client, "Secure", "Add", 1<<40, 2)
+	if err != nil || sum != 1<<40+2 {
+		t.Error(sum, err)
+	}
+
+	for _, opts := range [][]DialOption{nil, {WithHMACSigner("app1", []byte("wrong"))}, {WithHMACSigner("app2", []byte("secret"))}} {
+		client := pipeClient(server, opts...)
+		if _, err := client.Call("Secure", "WhoAmI", []interface{}{}); ErrorCode(err) != CodeUnauthenticated {
+			t.Error(err)
+		}
+		client.Close()
+	}
+
+	// 签名之后参数在路上被改了
+	tamperServer := NewServer(WithAuthenticator(auth))
+	tamperServer.Register(new(Secure), "Secure")
+	tamperServer.Use(func(next Handler) Handler {
+		return func(ctx context.Context, call *CallInfo) ([]any, error) {
+			call.InArgs = []any{100, 2}
+			return next(ctx, call)
+		}
+	})
+	tampered := pipeClient(tamperServer, WithHMACSigner("app1", []byte("secret")))
+	defer tampered.Close()
+	if _, err := tampered.Call("Secure", "Add", []interface{}{1, 2}); ErrorCode(err) != CodeUnauthenticated {
+		t.Error(err)
+	}
+
+	// 客户端拦截器改的参数也会被签名
+	double := WithInterceptor(func(next Invoker) Invoker {
+		return func(ctx context.Context, call *CallInfo) (*Out, error) {
+			call.InArgs = []any{10, 20}
+			return next(ctx, call)
+		}
+	})
+	rewritten := pipeClient(server, WithHMACSigner("app1", []byte("secret")), double)
+	defer rewritten.Close()
+	sum, err = Call1[int](context.Background(), rewritten, "Secure", "Add", 1, 2)
+	if err != nil || sum != 30 {
+		t.Error(sum, err)
+	}
+}
+
+func TestHMACReplay(t *testing.T) {
+	auth := HMACAuth(map[string][]byte{"app1": []byte("secret")}, time.Minute).(*hmacAuth)
+	server := NewServer(WithAuthenticator(auth))
+	server.Register(new(Secure), "Secure")
+
+	// 服务端记下签好名的metadata，另一个客户端原样重放一次
+	var signed Metadata
+	server.Use(func(next Handler) Handler {
+		return func(ctx context.Context, call *CallInfo) ([]any, error) {
+			if signed == nil {
+				signed = call.Metadata.copy()
+			}
+			return next(ctx, call)
+		}
+	})
+	client := pipeClient(server, WithHMACSigner("app1", []byte("secret")))
+	defer client.Close()
+	if _, err := client.Call("Secure", "WhoAmI", []interface{}{}); err != nil {
+		t.Fatal(err)
+	}
+
+	replay := pipeClient(server, WithInterceptor(func(next Invoker) Invoker {
+		return func(ctx context.Context, call *CallInfo) (*Out, error) {
+			call.Metadata = signed.copy()
+			return next(ctx, call)
+		}
+	}))
+	defer replay.Close()
+	_, err := replay.Call("Secure", "WhoAmI", []interface{}{})
+	if ErrorCode(err) != CodeUnauthenticated || err.Error() != "重复的请求" {
+		t.Error(err)
+	}
+
+	// 过期的请求
+	auth.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	fresh := pipeClient(server, WithHMACSigner("app1", []byte("secret")))
+	defer fresh.Close()
+	_, err = fresh.Call("Secure", "WhoAmI", []interface{}{})
+	if ErrorCode(err) != CodeUnauthenticated || err.Error() != "请求过期" {
+		t.Error(err)
+	}
+}
+
+func TestHMACRetry(t *testing.T) {
+	auth := HMACAuth(map[string][]byte{"app1": []byte("secret")}, time.Minute)
+	server := NewServer(WithAuthenticator(auth))
+	server.Register(new(Secure), "Secure")
+	server.MarkIdempotent("Secure", "WhoAmI")
+
+	// 认证通过之后第二次调用失败一次，重试要换一个nonce
+	calls := 0
+	server.Use(func(next Handler) Handler {
+		return func(ctx context.Context, call *CallInfo) ([]any, error) {
+			out, err := next(ctx, call)
+			if calls++; calls == 2 {
+				return nil, &Error{Code: CodeUnavailable, Message: "稍后再试"}
+			}
+			return out, err
+		}
+	})
+	client := pipeClient(server, WithHMACSigner("app1", []byte("secret")),
+		WithRetryPolicy("Secure", RetryPolicy{MaxAttempts: 2, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}))
+	defer client.Close()
+
+	for i := 0; i < 2; i++ {
+		who, err := Call1[string](context.Background(), client, "Secure", "WhoAmI")
+		if err != nil || who != "app1" {
+			t.Fatal(who, err)
+		}
+	}
+	if calls != 3 {
+		t.Error(calls)
+	}
+}
+
+func TestHMACNonceExpiry(t *testing.T) {
+	auth := HMACAuth(nil, time.Minute).(*hmacAuth)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if !auth.useNonce(strconv.Itoa(i), start.Add(time.Duration(i)*time.Minute)) {
+			t.Fatal(i)
+		}
+	}
+	if auth.useNonce("0", start.Add(time.Minute)) {
+		t.Error("2倍window内不能重复")
+	}
+
+	// 前两个过期了
+	if !auth.useNonce("3", start.Add(3*time.Minute+time.Second)) {
+		t.Fatal()
+	}
+	if len(auth.nonces) != 2 || len(auth.expiry) != 2 || auth.expiry[0].nonce != "2" {
+		t.Error(auth.nonces, auth.expiry)
+	}
+	if !auth.useNonce("0", start.Add(3*time.Minute+time.Second)) {
+		t.Error("过期的nonce可以再用")
+	}
+}
+
+func TestBearerAuthJSONRPC(t *testing.T) {
+	server := NewServer(WithAuthenticator(BearerTokens(map[string]string{"t0ken": "guobin"})))
+	server.Register(new(Secure), "Secure")
+	ts := httptest.NewServer(server.JSONRPCHandler())
+	defer ts.Close()
+
+	for _, tc := range []struct {
+		auth string
+		want string
+	}{
+		{"Bearer t0ken", `"result":"guobin"`},
+		{"", `"code":-32016`},
+		{"Bearer wrong", `"code":-32016`},
+	} {
+		req, _ := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader(`{"jsonrpc": "2.0", "method": "Secure.WhoAmI", "id": 1}`))
+		if tc.auth != "" {
+			req.Header.Set("Authorization", tc.auth)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		if !strings.Contains(string(body), tc.want) {
+			t.Error(tc.auth, string(body))
+		}
+	}
+}
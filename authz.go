@@ -0,0 +1,125 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+)
+
+// 在认证之后、转换参数之前调用，返回错误时调用以CodePermissionDenied失败
+type Authorizer interface {
+	Authorize(ctx context.Context, principal string, call *CallInfo) error
+}
+
+func (s *Server) authorize(next Handler) Handler {
+	return func(ctx context.Context, call *CallInfo) ([]any, error) {
+		principal, _ := PrincipalFromContext(ctx)
+		if err := s.opts.authorizer.Authorize(ctx, principal, call); err != nil {
+			var e *Error
+			if errors.As(err, &e) {
+				return nil, err
+			}
+			return nil, &Error{CodePermissionDenied, err.Error()}
+		}
+		return next(ctx, call)
+	}
+}
+
+// 按"Service.Method"授权，有匹配的deny规则就拒绝，否则有匹配的allow规则就允许，都没有时看DefaultDeny
+type Policy struct {
+	// 角色到身份的映射
+	Roles       map[string][]string `json:"roles"`
+	Rules       []Rule              `json:"rules"`
+	DefaultDeny bool                `json:"default_deny"`
+}
+
+type Rule struct {
+	// allow或者deny
+	Effect string `json:"effect"`
+	// path.Match的模式，比如"UserService.Add"、"UserService.*"、"*"
+	Methods []string `json:"methods"`
+	// 身份，"*"表示任何人，包括没有认证的
+	Principals []string `json:"principals,omitempty"`
+	Roles      []string `json:"roles,omitempty"`
+}
+
+// 从json文件加载
+func LoadPolicy(file string) (*Policy, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var p Policy
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, err
+	}
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (p *Policy) validate() error {
+	for i, rule := range p.Rules {
+		if rule.Effect != "allow" && rule.Effect != "deny" {
+			return fmt.Errorf("第%d条规则的effect不对: %s", i+1, rule.Effect)
+		}
+		for _, m := range rule.Methods {
+			if _, err := path.Match(m, ""); err != nil {
+				return fmt.Errorf("第%d条规则的方法不对: %s", i+1, m)
+			}
+		}
+	}
+	return nil
+}
+
+func (p *Policy) Authorize(ctx context.Context, principal string, call *CallInfo) error {
+	method := call.ServiceName + "." + call.MethodName
+	allowed := false
+	for _, rule := range p.Rules {
+		if !rule.matchMethod(method) || !p.matchPrincipal(rule, principal) {
+			continue
+		}
+		if rule.Effect == "deny" {
+			return errors.New("没有权限调用" + method)
+		}
+		if rule.Effect == "allow" {
+			allowed = true
+		}
+	}
+	if !allowed && p.DefaultDeny {
+		return errors.New("没有权限调用" + method)
+	}
+	return nil
+}
+
+func (r *Rule) matchMethod(method string) bool {
+	for _, pattern := range r.Methods {
+		if ok, _ := path.Match(pattern, method); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Policy) matchPrincipal(rule Rule, principal string) bool {
+	for _, pr := range rule.Principals {
+		if pr == "*" || pr == principal {
+			return true
+		}
+	}
+	if principal == "" {
+		return false
+	}
+	for _, role := range rule.Roles {
+		for _, member := range p.Roles[role] {
+			if member == principal {
+				return true
+			}
+		}
+	}
+	return false
+}
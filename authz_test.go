@@ -0,0 +1,88 @@
+package rpc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testPolicy = `{
+	"roles": {"admin": ["alice"], "reader": ["bob", "alice"]},
+	"default_deny": true,
+	"rules": [
+		{"effect": "allow", "methods": ["Secure.WhoAmI"], "principals": ["*"]},
+		{"effect": "allow", "methods": ["Secure.*"], "roles": ["admin"]},
+		{"effect": "allow", "methods": ["UserService.Get*"], "roles": ["reader"]},
+		{"effect": "deny", "methods": ["Secure.Add"], "principals": ["mallory"]},
+		{"effect": "allow", "methods": ["Secure.Add"], "principals": ["mallory"]}
+	]
+}`
+
+func TestPolicy(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "policy.json")
+	os.WriteFile(file, []byte(testPolicy), 0644)
+	policy, err := LoadPolicy(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewServer(
+		WithAuthenticator(BearerTokens(map[string]string{"a": "alice", "b": "bob", "m": "mallory"})),
+		WithAuthorizer(policy))
+	server.Register(new(Secure), "Secure")
+	server.Register(new(Userservice), "UserService")
+
+	tests := []struct {
+		token, service, method string
+		args                   []interface{}
+		code                   Code
+	}{
+		{"a", "Secure", "Add", []interface{}{1, 2}, CodeOK},
+		{"a", "UserService", "GetUserById", []interface{}{1}, CodeOK},
+		{"a", "UserService", "Add", []interface{}{1, 2}, CodePermissionDenied},
+		{"b", "Secure", "WhoAmI", []interface{}{}, CodeOK},
+		{"b", "Secure", "Add", []interface{}{1, 2}, CodePermissionDenied},
+		{"b", "UserService", "GetUserById", []interface{}{1}, CodeOK},
+		// deny优先
+		{"m", "Secure", "Add", []interface{}{1, 2}, CodePermissionDenied},
+		// 授权在参数检查之前
+		{"b", "Secure", "Add", []interface{}{"x"}, CodePermissionDenied},
+		{"", "Secure", "WhoAmI", []interface{}{}, CodeUnauthenticated},
+	}
+	for _, tt := range tests {
+		var opts []DialOption
+		if tt.token != "" {
+			opts = append(opts, WithBearerToken(tt.token))
+		}
+		client := pipeClient(server, opts...)
+		_, err := client.Call(tt.service, tt.method, tt.args)
+		if ErrorCode(err) != tt.code {
+			t.Error(tt.token, tt.service, tt.method, err)
+		}
+		client.Close()
+	}
+}
+
+func TestPolicyAllowByDefault(t *testing.T) {
+	policy := &Policy{Rules: []Rule{{Effect: "deny", Methods: []string{"UserService.Add"}, Principals: []string{"*"}}}}
+	server := NewServer(WithAuthorizer(policy))
+	server.Register(new(Userservice), "UserService")
+	client := pipeClient(server)
+	defer client.Close()
+
+	if _, err := client.Call("UserService", "GetUserById", []interface{}{1}); err != nil {
+		t.Error(err)
+	}
+	_, err := client.Call("UserService", "Add", []interface{}{1, 2})
+	if ErrorCode(err) != CodePermissionDenied {
+		t.Error(err)
+	}
+}
+
+func TestLoadPolicyInvalid(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "policy.json")
+	os.WriteFile(file, []byte(`{"rules": [{"effect": "maybe", "methods": ["*"]}]}`), 0644)
+	if _, err := LoadPolicy(file); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,157 @@
+package rpc
+
+import (
+	"context"
+	"hash/crc32"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Balancer决定一次调用用Pool里的哪个连接，每个Pool有自己的状态
+type Balancer interface {
+	newPicker() picker
+}
+
+type picker interface {
+	// 在p.mu里调用，只能选client不为nil的连接，都不可用时返回nil
+	pick(conns []*poolConn, call *pickCall) *poolConn
+}
+
+type pickCall struct {
+	ctx         context.Context
+	serviceName string
+	methodName  string
+	inArgs      []any
+}
+
+type balancerFunc func() picker
+
+func (f balancerFunc) newPicker() picker {
+	return f()
+}
+
+var (
+	// 轮流使用每个可用的连接
+	RoundRobin Balancer = balancerFunc(func() picker { return &roundRobinPicker{} })
+	// 随机选一个可用的连接
+	Random Balancer = balancerFunc(func() picker { return randomPicker{} })
+	// 选正在进行的调用最少的连接，一样多的时候轮流
+	LeastPending Balancer = balancerFunc(func() picker { return &leastPendingPicker{} })
+)
+
+// 同一个key总是落在同一个地址上，这个地址不可用时顺着哈希环找下一个
+func ConsistentHash(key func(ctx context.Context, serviceName, methodName string, inArgs []any) string) Balancer {
+	return balancerFunc(func() picker { return &consistentHashPicker{key: key} })
+}
+
+type roundRobinPicker struct {
+	next int
+}
+
+func (r *roundRobinPicker) pick(conns []*poolConn, call *pickCall) *poolConn {
+	n := len(conns)
+	for i := 0; i < n; i++ {
+		pc := conns[(r.next+i)%n]
+		if pc.client != nil {
+			r.next = (r.next + i + 1) % n
+			return pc
+		}
+	}
+	return nil
+}
+
+type randomPicker struct{}
+
+func (randomPicker) pick(conns []*poolConn, call *pickCall) *poolConn {
+	var ready []*poolConn
+	for _, pc := range conns {
+		if pc.client != nil {
+			ready = append(ready, pc)
+		}
+	}
+	if len(ready) == 0 {
+		return nil
+	}
+	return ready[rand.Intn(len(ready))]
+}
+
+type leastPendingPicker struct {
+	next int
+}
+
+func (l *leastPendingPicker) pick(conns []*poolConn, call *pickCall) *poolConn {
+	var picked *poolConn
+	n := len(conns)
+	for i := 0; i < n; i++ {
+		pc := conns[(l.next+i)%n]
+		if pc.client != nil && (picked == nil || pc.pending < picked.pending) {
+			picked = pc
+		}
+	}
+	l.next = (l.next + 1) % n
+	return picked
+}
+
+const hashReplicas = 100
+
+type consistentHashPicker struct {
+	key func(ctx context.Context, serviceName, methodName string, inArgs []any) string
+
+	// 地址变了才重建哈希环
+	addrs  string
+	hashes []uint32
+	owners map[uint32]string
+}
+
+func (c *consistentHashPicker) pick(conns []*poolConn, call *pickCall) *poolConn {
+	c.build(conns)
+	if len(c.hashes) == 0 {
+		return nil
+	}
+
+	h := crc32.ChecksumIEEE([]byte(c.key(call.ctx, call.serviceName, call.methodName, call.inArgs)))
+	start := sort.Search(len(c.hashes), func(i int) bool { return c.hashes[i] >= h })
+	tried := make(map[string]bool)
+	for i := 0; i < len(c.hashes); i++ {
+		addr := c.owners[c.hashes[(start+i)%len(c.hashes)]]
+		if tried[addr] {
+			continue
+		}
+		tried[addr] = true
+		for _, pc := range conns {
+			if pc.client != nil && pc.network+"://"+pc.address == addr {
+				return pc
+			}
+		}
+	}
+	return nil
+}
+
+func (c *consistentHashPicker) build(conns []*poolConn) {
+	var addrs []string
+	for _, pc := range conns {
+		addrs = append(addrs, pc.network+"://"+pc.address)
+	}
+	sort.Strings(addrs)
+	key := strings.Join(addrs, ",")
+	if key == c.addrs {
+		return
+	}
+
+	c.addrs = key
+	c.hashes = nil
+	c.owners = make(map[uint32]string)
+	for _, addr := range addrs {
+		for i := 0; i < hashReplicas; i++ {
+			h := crc32.ChecksumIEEE([]byte(strconv.Itoa(i) + addr))
+			if _, ok := c.owners[h]; ok {
+				continue
+			}
+			c.owners[h] = addr
+			c.hashes = append(c.hashes, h)
+		}
+	}
+	sort.Slice(c.hashes, func(i, j int) bool { return c.hashes[i] < c.hashes[j] })
+}
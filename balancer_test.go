@@ -0,0 +1,181 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+type Named struct {
+	name string
+}
+
+func (n *Named) Name() string {
+	return n.name
+}
+
+func (n *Named) Echo(key string) string {
+	return n.name
+}
+
+func startNamedServer(t *testing.T, name, address string) *trackListener {
+	server := NewServer()
+	server.Register(&Named{name}, "Named")
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &trackListener{Listener: ln}
+	go server.Serve(l)
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+func startNamedServers(t *testing.T, n int) ([]*trackListener, []string) {
+	var ls []*trackListener
+	var addrs []string
+	for i := 0; i < n; i++ {
+		l := startNamedServer(t, fmt.Sprint("s", i), "127.0.0.1:0")
+		ls = append(ls, l)
+		addrs = append(addrs, l.Addr().String())
+	}
+	return ls, addrs
+}
+
+func callName(pool *Pool) (string, error) {
+	return Call1[string](context.Background(), pool, "Named", "Name")
+}
+
+func TestDialMultiRoundRobin(t *testing.T) {
+	_, addrs := startNamedServers(t, 3)
+	pool, err := DialMulti(addrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	var names []string
+	for i := 0; i < 6; i++ {
+		name, err := callName(pool)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	if fmt.Sprint(names) != "[s0 s1 s2 s0 s1 s2]" {
+		t.Error(names)
+	}
+}
+
+func TestDialMultiRandom(t *testing.T) {
+	_, addrs := startNamedServers(t, 3)
+	pool, err := DialMulti(addrs, WithBalancer(Random))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		name, err := callName(pool)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[name] = true
+	}
+	if len(seen) != 3 {
+		t.Error(seen)
+	}
+}
+
+func TestDialMultiConsistentHash(t *testing.T) {
+	ls, addrs := startNamedServers(t, 3)
+	pool, err := DialMulti(addrs,
+		WithBalancer(ConsistentHash(func(ctx context.Context, serviceName, methodName string, inArgs []any) string {
+			return inArgs[0].(string)
+		})),
+		WithBackoff(time.Hour, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	owners := make(map[string]string)
+	used := make(map[string]bool)
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprint("user-", i)
+		name, err := Call1[string](context.Background(), pool, "Named", "Echo", key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		owners[key] = name
+		used[name] = true
+
+		again, _ := Call1[string](context.Background(), pool, "Named", "Echo", key)
+		if again != name {
+			t.Error(key, name, again)
+		}
+	}
+	if len(used) != 3 {
+		t.Error(used)
+	}
+
+	// s0挂掉，只有原来在s0上的key会换地方
+	ls[0].Close()
+	ls[0].closeConns()
+	for key, owner := range owners {
+		name, err := Call1[string](context.Background(), pool, "Named", "Echo", key)
+		if err != nil {
+			// 第一次发现连接断了
+			name, err = Call1[string](context.Background(), pool, "Named", "Echo", key)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if owner != "s0" && name != owner || name == "s0" {
+			t.Error(key, owner, name)
+		}
+	}
+}
+
+func TestDialMultiProbe(t *testing.T) {
+	_, addrs := startNamedServers(t, 1)
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	down := l.Addr().String()
+	l.Close()
+
+	pool, err := DialMulti([]string{addrs[0], "tcp://" + down}, WithBackoff(10*time.Millisecond, 20*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	for i := 0; i < 4; i++ {
+		if name, err := callName(pool); err != nil || name != "s0" {
+			t.Error(name, err)
+		}
+	}
+
+	startNamedServer(t, "s1", down)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if name, _ := callName(pool); name == "s1" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("endpoint not probed back in")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDialMultiAllDown(t *testing.T) {
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	down := l.Addr().String()
+	l.Close()
+	if _, err := DialMulti([]string{down}); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,228 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("熔断器打开")
+
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "Closed"
+	case BreakerOpen:
+		return "Open"
+	case BreakerHalfOpen:
+		return "HalfOpen"
+	}
+	return fmt.Sprintf("BreakerState(%d)", int(s))
+}
+
+// 每个地址的每个方法一个熔断器，零值的字段用默认值
+type BreakerConfig struct {
+	// 统计的时间窗口，默认10秒
+	Window time.Duration
+	// 窗口内至少有这么多调用才会熔断，默认20
+	MinRequests int
+	// 失败率达到这个值就熔断，默认0.5
+	FailureRate float64
+	// 超过SlowCall的调用算慢调用，慢调用的比例达到SlowCallRate也会熔断，SlowCall为0时不统计
+	SlowCall     time.Duration
+	SlowCallRate float64
+	// 熔断多久之后放少量调用试探，默认5秒
+	OpenTimeout time.Duration
+	// 试探的调用数，都成功才恢复，默认1
+	HalfOpenRequests int
+	// 状态变化时调用，endpoint是地址，单个连接的Client是空字符串
+	OnStateChange func(endpoint, method string, from, to BreakerState)
+}
+
+type breakers struct {
+	config BreakerConfig
+
+	mu       sync.Mutex
+	breakers map[string]*breaker
+}
+
+func newBreakers(config *BreakerConfig) *breakers {
+	if config == nil {
+		return nil
+	}
+	c := *config
+	if c.Window <= 0 {
+		c.Window = 10 * time.Second
+	}
+	if c.MinRequests <= 0 {
+		c.MinRequests = 20
+	}
+	if c.FailureRate <= 0 {
+		c.FailureRate = 0.5
+	}
+	if c.SlowCallRate <= 0 {
+		c.SlowCallRate = 1
+	}
+	if c.OpenTimeout <= 0 {
+		c.OpenTimeout = 5 * time.Second
+	}
+	if c.HalfOpenRequests <= 0 {
+		c.HalfOpenRequests = 1
+	}
+	return &breakers{config: c, breakers: make(map[string]*breaker)}
+}
+
+func (bs *breakers) get(endpoint, method string) *breaker {
+	if bs == nil {
+		return nil
+	}
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	key := endpoint + " " + method
+	b, ok := bs.breakers[key]
+	if !ok {
+		b = &breaker{config: &bs.config, endpoint: endpoint, method: method}
+		bs.breakers[key] = b
+	}
+	return b
+}
+
+type breaker struct {
+	config   *BreakerConfig
+	endpoint string
+	method   string
+
+	mu          sync.Mutex
+	state       BreakerState
+	generation  uint64
+	windowStart time.Time
+	total       int
+	failures    int
+	slow        int
+	openedAt    time.Time
+	probes      int
+	successes   int
+}
+
+// 返回能不能发出这次调用，还有放行时的generation
+// 状态变了以后generation会变，之前放行的调用结束时不再统计
+func (b *breaker) allow() (uint64, bool) {
+	b.mu.Lock()
+	from := b.state
+	allowed := true
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.config.OpenTimeout {
+			allowed = false
+			break
+		}
+		b.setState(BreakerHalfOpen)
+		b.probes, b.successes = 1, 0
+	case BreakerHalfOpen:
+		if b.probes >= b.config.HalfOpenRequests {
+			allowed = false
+			break
+		}
+		b.probes++
+	}
+	to := b.state
+	generation := b.generation
+	b.mu.Unlock()
+
+	b.notify(from, to)
+	return generation, allowed
+}
+
+func (b *breaker) done(generation uint64, err error, latency time.Duration) {
+	canceled := errors.Is(err, context.Canceled)
+	failed := breakerFailure(err)
+	slow := b.config.SlowCall > 0 && latency > b.config.SlowCall
+
+	b.mu.Lock()
+	if generation != b.generation {
+		b.mu.Unlock()
+		return
+	}
+	from := b.state
+	now := time.Now()
+	switch b.state {
+	case BreakerClosed:
+		if canceled {
+			break
+		}
+		if now.Sub(b.windowStart) > b.config.Window {
+			b.windowStart = now
+			b.total, b.failures, b.slow = 0, 0, 0
+		}
+		b.total++
+		if failed {
+			b.failures++
+		}
+		if slow {
+			b.slow++
+		}
+		if b.total >= b.config.MinRequests &&
+			(float64(b.failures) >= b.config.FailureRate*float64(b.total) ||
+				b.config.SlowCall > 0 && float64(b.slow) >= b.config.SlowCallRate*float64(b.total)) {
+			b.open(now)
+		}
+	case BreakerHalfOpen:
+		// 取消的试探不算结果，把名额还回去
+		if canceled {
+			b.probes--
+			break
+		}
+		if failed || slow {
+			b.open(now)
+			break
+		}
+		b.successes++
+		if b.successes >= b.config.HalfOpenRequests {
+			b.setState(BreakerClosed)
+			b.windowStart = now
+			b.total, b.failures, b.slow = 0, 0, 0
+		}
+	}
+	to := b.state
+	b.mu.Unlock()
+
+	b.notify(from, to)
+}
+
+func (b *breaker) setState(state BreakerState) {
+	b.state = state
+	b.generation++
+}
+
+func (b *breaker) open(now time.Time) {
+	b.setState(BreakerOpen)
+	b.openedAt = now
+}
+
+func (b *breaker) notify(from, to BreakerState) {
+	if from != to && b.config.OnStateChange != nil {
+		b.config.OnStateChange(b.endpoint, b.method, from, to)
+	}
+}
+
+// 参数不对、没权限这种调用方的错误不算失败，调用方自己取消的也不算
+func breakerFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	switch ErrorCode(err) {
+	case CodeInvalidArgument, CodeNotFound, CodePermissionDenied, CodeUnauthenticated:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,214 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+type stateChanges struct {
+	mu      sync.Mutex
+	changes []string
+}
+
+func (s *stateChanges) record(endpoint, method string, from, to BreakerState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.changes = append(s.changes, method+" "+from.String()+"->"+to.String())
+}
+
+func (s *stateChanges) get() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.changes...)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	h, url := newFlakyServer(t)
+	var changes stateChanges
+	client, _ := DialHTTP(url, WithCircuitBreaker(BreakerConfig{
+		MinRequests:   4,
+		FailureRate:   0.5,
+		OpenTimeout:   50 * time.Millisecond,
+		OnStateChange: changes.record,
+	}))
+
+	h.failures.Store(2)
+	for i := 0; i < 4; i++ {
+		client.Call("UserService", "GetUserById", []interface{}{1})
+	}
+	h.requests.Store(0)
+	if _, err := client.Call("UserService", "GetUserById", []interface{}{1}); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatal(err)
+	}
+	if n := h.requests.Load(); n != 0 {
+		t.Error(n)
+	}
+	// 每个方法单独统计
+	if _, err := client.Call("UserService", "Add", []interface{}{1, 2}); err != nil {
+		t.Error(err)
+	}
+
+	// 试探失败又回到熔断
+	time.Sleep(60 * time.Millisecond)
+	h.failures.Store(1)
+	if _, err := client.Call("UserService", "GetUserById", []interface{}{1}); ErrorCode(err) != CodeUnavailable {
+		t.Fatal(err)
+	}
+	if _, err := client.Call("UserService", "GetUserById", []interface{}{1}); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatal(err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if _, err := client.Call("UserService", "GetUserById", []interface{}{1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []string{
+		"UserService.GetUserById Closed->Open",
+		"UserService.GetUserById Open->HalfOpen",
+		"UserService.GetUserById HalfOpen->Open",
+		"UserService.GetUserById Open->HalfOpen",
+		"UserService.GetUserById HalfOpen->Closed",
+	}
+	if got := changes.get(); len(got) != len(want) {
+		t.Fatal(got)
+	} else {
+		for i := range want {
+			if got[i] != want[i] {
+				t.Error(i, got[i])
+			}
+		}
+	}
+}
+
+func TestCircuitBreakerSlowCall(t *testing.T) {
+	server := NewServer()
+	server.Register(new(Userservice), "UserService")
+	client := pipeClient(server, WithCircuitBreaker(BreakerConfig{
+		MinRequests:  2,
+		SlowCall:     10 * time.Millisecond,
+		SlowCallRate: 0.5,
+		OpenTimeout:  time.Minute,
+	}))
+	defer client.Close()
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.Call("UserService", "Sleep", []interface{}{20}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := client.Call("UserService", "Sleep", []interface{}{0}); !errors.Is(err, ErrCircuitOpen) {
+		t.Error(err)
+	}
+}
+
+func TestCircuitBreakerIgnoresClientErrors(t *testing.T) {
+	_, url := newFlakyServer(t)
+	client, _ := DialHTTP(url, WithCircuitBreaker(BreakerConfig{MinRequests: 2}))
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.Call("UserService", "Missing", nil); ErrorCode(err) != CodeNotFound {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	client.CallContext(ctx, "UserService", "Add", []interface{}{1, 2})
+	if _, err := client.Call("UserService", "Missing", nil); ErrorCode(err) != CodeNotFound {
+		t.Error(err)
+	}
+}
+
+func TestCircuitBreakerPerEndpoint(t *testing.T) {
+	good := startAddrServer(t)
+	server := NewServer()
+	server.Register(new(Addr), "Addr")
+	server.Use(func(next Handler) Handler {
+		return func(ctx context.Context, call *CallInfo) ([]any, error) {
+			return nil, &Error{Code: CodeUnavailable, Message: "挂了"}
+		}
+	})
+	bad, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bad.Close()
+	go server.Serve(bad)
+
+	var changes stateChanges
+	pool, err := DialMulti([]string{good.Addr().String(), bad.Addr().String()}, WithCircuitBreaker(BreakerConfig{
+		MinRequests:   2,
+		OpenTimeout:   time.Minute,
+		OnStateChange: changes.record,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	var ok, open int
+	for i := 0; i < 12; i++ {
+		_, err := Call1[string](context.Background(), pool, "Addr", "Remote")
+		switch {
+		case err == nil:
+			ok++
+		case errors.Is(err, ErrCircuitOpen):
+			open++
+		}
+	}
+	if ok != 6 || open != 4 {
+		t.Error(ok, open)
+	}
+	if got := changes.get(); len(got) != 1 || got[0] != "Addr.Remote Closed->Open" {
+		t.Error(got)
+	}
+}
+
+func TestBreakerStaleAndCanceledCalls(t *testing.T) {
+	b := newBreakers(&BreakerConfig{MinRequests: 2, OpenTimeout: 10 * time.Millisecond}).get("", "S.M")
+	failure := &Error{Code: CodeUnavailable, Message: "挂了"}
+
+	// 熔断之前放行的调用
+	stale, _ := b.allow()
+	for i := 0; i < 2; i++ {
+		generation, _ := b.allow()
+		b.done(generation, failure, 0)
+	}
+	if b.state != BreakerOpen {
+		t.Fatal(b.state)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	probe, ok := b.allow()
+	if !ok || b.state != BreakerHalfOpen {
+		t.Fatal(ok, b.state)
+	}
+	// 旧的调用结束了不能当作试探成功
+	b.done(stale, nil, 0)
+	if b.state != BreakerHalfOpen {
+		t.Fatal(b.state)
+	}
+
+	// 取消的试探不算成功，名额还回去
+	b.done(probe, context.Canceled, 0)
+	if b.state != BreakerHalfOpen {
+		t.Fatal(b.state)
+	}
+	probe, ok = b.allow()
+	if !ok {
+		t.Fatal("取消的试探没有还回名额")
+	}
+	if _, ok := b.allow(); ok {
+		t.Error("只能有一个试探")
+	}
+	b.done(probe, nil, 0)
+	if b.state != BreakerClosed {
+		t.Error(b.state)
+	}
+}
@@ -0,0 +1,48 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+)
+
+type Caller interface {
+	CallContext(ctx context.Context, serviceName, methodName string, inArgs []any) (*Out, error)
+}
+
+func Call1[R any](ctx context.Context, c Caller, serviceName, methodName string, inArgs ...any) (R, error) {
+	var r R
+	out, err := call(ctx, c, serviceName, methodName, inArgs, 1)
+	if err != nil {
+		return r, err
+	}
+	err = out.Decode(0, &r)
+	return r, err
+}
+
+func Call2[R1, R2 any](ctx context.Context, c Caller, serviceName, methodName string, inArgs ...any) (R1, R2, error) {
+	var r1 R1
+	var r2 R2
+	out, err := call(ctx, c, serviceName, methodName, inArgs, 2)
+	if err != nil {
+		return r1, r2, err
+	}
+	if err := out.Decode(0, &r1); err != nil {
+		return r1, r2, err
+	}
+	err = out.Decode(1, &r2)
+	return r1, r2, err
+}
+
+func call(ctx context.Context, c Caller, serviceName, methodName string, inArgs []any, n int) (*Out, error) {
+	if inArgs == nil {
+		inArgs = []any{}
+	}
+	out, err := c.CallContext(ctx, serviceName, methodName, inArgs)
+	if err != nil {
+		return nil, err
+	}
+	if out.Len() < n {
+		return nil, errors.New("返回值个数不匹配")
+	}
+	return out, nil
+}
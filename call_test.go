@@ -0,0 +1,138 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+)
+
+type indentCodec struct{}
+
+func (indentCodec) Name() string {
+	return "json-indent"
+}
+
+func (indentCodec) NewEncoder(w io.Writer) Encoder {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e
+}
+
+func (indentCodec) NewDecoder(r io.Reader) Decoder {
+	return json.NewDecoder(r)
+}
+
+func (indentCodec) Convert(src any, dst any) error {
+	return JSONCodec.Convert(src, dst)
+}
+
+func TestCall1(t *testing.T) {
+	server := NewServer()
+	server.Register(new(Userservice), "UserService")
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go server.ServeConn(conn)
+		}
+	}()
+
+	client, _ := Dial("tcp", l.Addr().String())
+	sum, err := Call1[int](context.Background(), client, "UserService", "Add", 1, 2)
+	if err != nil {
+		t.Error(err)
+	}
+	if sum != 3 {
+		t.Error(sum)
+	}
+
+	u, err := Call1[*user](context.Background(), client, "UserService", "GetUserById", 1)
+	if err != nil {
+		t.Error(err)
+	}
+	if u.ID != 1 || u.Name != "Guobin" {
+		t.Error(u)
+	}
+
+	name, err := Call1[string](context.Background(), client, "UserService", "EmptyIn")
+	if err != nil {
+		t.Error(err)
+	}
+	if name != "guobin" {
+		t.Error(name)
+	}
+
+	_, err = Call1[int](context.Background(), client, "UserService", "EmptyInAndOut")
+	if err == nil {
+		t.Error("expected error")
+	}
+	t.Log(err)
+
+	client.Close()
+	l.Close()
+}
+
+func TestCall2(t *testing.T) {
+	server := NewServer()
+	server.Register(new(Userservice), "UserService")
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go server.ServeConn(conn)
+		}
+	}()
+
+	client, _ := Dial("tcp", l.Addr().String())
+	q, r, err := Call2[int, int](context.Background(), client, "UserService", "DivMod", 7, 2)
+	if err != nil {
+		t.Error(err)
+	}
+	if q != 3 || r != 1 {
+		t.Error(q, r)
+	}
+
+	client.Close()
+	l.Close()
+}
+
+func TestCallWithCodec(t *testing.T) {
+	RegisterCodec(indentCodec{})
+	codec, ok := GetCodec("json-indent")
+	if !ok {
+		t.Fatal("codec not registered")
+	}
+
+	server := NewServer(WithServerCodec(codec))
+	server.Register(new(Userservice), "UserService")
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go server.ServeConn(conn)
+		}
+	}()
+
+	client, _ := Dial("tcp", l.Addr().String(), WithCodec(codec))
+	sum, err := Call1[int](context.Background(), client, "UserService", "Add", 1, 2)
+	if err != nil {
+		t.Error(err)
+	}
+	if sum != 3 {
+		t.Error(sum)
+	}
+
+	client.Close()
+	l.Close()
+}
@@ -0,0 +1,90 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestClientInterceptor(t *testing.T) {
+	server := NewServer()
+	server.Register(new(Userservice), "UserService")
+	serverConn, clientConn := net.Pipe()
+	go server.ServeConn(serverConn)
+
+	var trace []string
+	record := func(name string) ClientInterceptor {
+		return func(next Invoker) Invoker {
+			return func(ctx context.Context, call *CallInfo) (*Out, error) {
+				trace = append(trace, name+">")
+				out, err := next(ctx, call)
+				trace = append(trace, "<"+name)
+				return out, err
+			}
+		}
+	}
+	double := func(next Invoker) Invoker {
+		return func(ctx context.Context, call *CallInfo) (*Out, error) {
+			if call.MethodName == "Add" {
+				call.InArgs = []any{call.InArgs[0], call.InArgs[1].(int) * 2}
+			}
+			return next(ctx, call)
+		}
+	}
+
+	client := DialConn(clientConn, WithInterceptor(record("a"), record("b")), WithInterceptor(double))
+	defer client.Close()
+
+	sum, err := Call1[int](context.Background(), client, "UserService", "Add", 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum != 5 {
+		t.Error(sum)
+	}
+	if got := strings.Join(trace, " "); got != "a> b> <b <a" {
+		t.Error(got)
+	}
+
+	// 拦截器能看到服务端返回的错误
+	trace = nil
+	_, err = client.Call("UserService", "Nope", []interface{}{})
+	if ErrorCode(err) != CodeNotFound || len(trace) != 4 {
+		t.Error(err, trace)
+	}
+}
+
+func TestClientInterceptorMock(t *testing.T) {
+	mock := func(next Invoker) Invoker {
+		return func(ctx context.Context, call *CallInfo) (*Out, error) {
+			switch call.ServiceName + "." + call.MethodName {
+			case "UserService.GetUserById":
+				return NewOut(map[string]any{"ID": call.InArgs[0], "Name": "mock"}), nil
+			case "UserService.Fail":
+				return nil, errors.New("mock error")
+			}
+			return next(ctx, call)
+		}
+	}
+
+	// 连接没有服务端，没被mock的调用会失败
+	_, clientConn := net.Pipe()
+	clientConn.Close()
+	client := DialConn(clientConn, WithInterceptor(mock))
+
+	u, err := Call1[*user](context.Background(), client, "UserService", "GetUserById", int64(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.ID != 7 || u.Name != "mock" {
+		t.Error(u)
+	}
+	if _, err := client.Call("UserService", "Fail", []interface{}{}); err == nil || err.Error() != "mock error" {
+		t.Error(err)
+	}
+	if _, err := client.Call("UserService", "Add", []interface{}{1, 2}); err == nil {
+		t.Error("expected error")
+	}
+}
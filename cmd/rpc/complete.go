@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/guobinqiu/rpc"
+)
+
+type completer struct {
+	services []string
+	methods  map[string]*rpc.MethodSchema
+	defs     map[string]map[string]*rpc.JSONSchema
+}
+
+func newCompleter(schemas []*rpc.ServiceSchema) *completer {
+	c := &completer{
+		methods: make(map[string]*rpc.MethodSchema),
+		defs:    make(map[string]map[string]*rpc.JSONSchema),
+	}
+	for _, schema := range schemas {
+		c.services = append(c.services, schema.Name)
+		c.defs[schema.Name] = schema.Defs
+		for _, m := range schema.Methods {
+			c.methods[schema.Name+"."+m.Name] = m
+		}
+	}
+	return c
+}
+
+func (c *completer) methodNames() []string {
+	names := make([]string, 0, len(c.methods))
+	for name := range c.methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 返回保留不变的前半部分和最后一个词的候选项
+func (c *completer) complete(line string) (string, []string) {
+	words := splitArgs(line)
+	if len(words) == 0 || strings.HasSuffix(line, " ") || strings.HasSuffix(line, "\t") {
+		words = append(words, "")
+	}
+	word := words[len(words)-1]
+	head := line[:len(line)-len(word)]
+
+	var candidates []string
+	switch {
+	case len(words) == 1:
+		candidates = append(candidates, shellCommands...)
+		candidates = append(candidates, c.methodNames()...)
+	case len(words) == 2 && words[0] == "describe":
+		candidates = append(candidates, c.services...)
+		candidates = append(candidates, c.methodNames()...)
+	default:
+		m, ok := c.methods[words[0]]
+		if !ok || len(words)-2 >= len(m.Input.PrefixItems) {
+			return head, nil
+		}
+		serviceName, _, _ := splitMethod(words[0])
+		candidates = append(candidates, argTemplate(m.Input.PrefixItems[len(words)-2], c.defs[serviceName]))
+	}
+
+	var matched []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, word) {
+			matched = append(matched, candidate)
+		}
+	}
+	return head, matched
+}
+
+func argTemplate(schema *rpc.JSONSchema, defs map[string]*rpc.JSONSchema) string {
+	b, _ := json.Marshal(templateValue(schema, defs, make(map[string]bool)))
+	return string(b)
+}
+
+func templateValue(schema *rpc.JSONSchema, defs map[string]*rpc.JSONSchema, seen map[string]bool) any {
+	if schema.Ref != "" {
+		name := strings.TrimPrefix(schema.Ref, "#/$defs/")
+		def, ok := defs[strings.NewReplacer("~1", "/", "~0", "~").Replace(name)]
+		if !ok || seen[name] {
+			return nil
+		}
+		seen[name] = true
+		defer delete(seen, name)
+		return templateValue(def, defs, seen)
+	}
+
+	switch schema.Type {
+	case "boolean":
+		return false
+	case "integer", "number":
+		return 0
+	case "string":
+		if schema.Format == "date-time" {
+			return time.Now().Format(time.RFC3339)
+		}
+		return ""
+	case "array":
+		return []any{}
+	case "object":
+		v := make(map[string]any)
+		for name, prop := range schema.Properties {
+			v[name] = templateValue(prop, defs, seen)
+		}
+		return v
+	}
+	return nil
+}
+
+func commonPrefix(words []string) string {
+	if len(words) == 0 {
+		return ""
+	}
+	prefix := words[0]
+	for _, w := range words[1:] {
+		for !strings.HasPrefix(w, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+// 按空白切分参数，json字符串、对象和数组里的空白不切
+func splitArgs(line string) []string {
+	var args []string
+	var cur strings.Builder
+	depth := 0
+	inString := false
+	escaped := false
+	started := false
+
+	for _, r := range line {
+		switch {
+		case inString:
+			if escaped {
+				escaped = false
+			} else if r == '\\' {
+				escaped = true
+			} else if r == '"' {
+				inString = false
+			}
+		case r == '"':
+			inString = true
+		case r == '{' || r == '[':
+			depth++
+		case (r == '}' || r == ']') && depth > 0:
+			depth--
+		case (r == ' ' || r == '\t') && depth == 0:
+			if started {
+				args = append(args, cur.String())
+				cur.Reset()
+				started = false
+			}
+			continue
+		}
+		cur.WriteRune(r)
+		started = true
+	}
+	if started {
+		args = append(args, cur.String())
+	}
+	return args
+}
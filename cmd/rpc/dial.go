@@ -0,0 +1,78 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/guobinqiu/rpc"
+)
+
+// tcp://host:port, unix:///path/to/sock, http://host:port/rpc，没有scheme默认是tcp
+func parseTarget(target string) (string, string) {
+	if i := strings.Index(target, "://"); i >= 0 {
+		return target[:i], target[i+3:]
+	}
+	return "tcp", target
+}
+
+func dial(o *options, target string) (*rpc.Client, error) {
+	codec, ok := rpc.GetCodec(o.codec)
+	if !ok {
+		return nil, fmt.Errorf("不支持的编码: %s", o.codec)
+	}
+
+	network, address := parseTarget(target)
+	if network == "http" || network == "https" {
+		return rpc.DialHTTP(target, rpc.WithCodec(codec))
+	}
+	dialer := &net.Dialer{Timeout: o.timeout}
+
+	var conn net.Conn
+	var err error
+	if o.tls {
+		var config *tls.Config
+		config, err = o.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		conn, err = tls.DialWithDialer(dialer, network, address, config)
+	} else {
+		conn, err = dialer.Dial(network, address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rpc.NewClientWithConn(conn, rpc.WithCodec(codec)), nil
+}
+
+func (o *options) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         o.serverName,
+		InsecureSkipVerify: o.insecure,
+	}
+
+	if o.caFile != "" {
+		pem, err := os.ReadFile(o.caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("CA证书格式不对")
+		}
+	}
+
+	if o.certFile != "" || o.keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.certFile, o.keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
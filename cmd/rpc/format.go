@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/guobinqiu/rpc"
+)
+
+// Add(integer, integer) (integer)
+func signature(m *rpc.MethodSchema) string {
+	return m.Name + "(" + tupleNames(m.Input) + ") (" + tupleNames(m.Output) + ")"
+}
+
+func tupleNames(schema *rpc.JSONSchema) string {
+	names := make([]string, 0, len(schema.PrefixItems))
+	for _, item := range schema.PrefixItems {
+		names = append(names, typeName(item))
+	}
+	return strings.Join(names, ", ")
+}
+
+func typeName(schema *rpc.JSONSchema) string {
+	switch {
+	case schema.Ref != "":
+		return strings.TrimPrefix(schema.Ref, "#/$defs/")
+	case schema.Type == "array" && schema.Items != nil:
+		if schema.MaxItems != nil {
+			return "[" + strconv.Itoa(*schema.MaxItems) + "]" + typeName(schema.Items)
+		}
+		return "[]" + typeName(schema.Items)
+	case schema.Format != "":
+		return schema.Format
+	case schema.Type != "":
+		return schema.Type
+	}
+	return "any"
+}
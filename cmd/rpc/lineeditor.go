@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+var errInterrupted = errors.New("interrupted")
+
+// 一个够用的行编辑器：退格、上下键翻历史、tab补全、Ctrl-C清空当前行、空行Ctrl-D退出
+type lineEditor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	prompt   string
+	history  []string
+	complete func(line string) (string, []string)
+}
+
+func newLineEditor(in io.Reader, out io.Writer, prompt string) *lineEditor {
+	return &lineEditor{
+		in:     bufio.NewReader(in),
+		out:    out,
+		prompt: prompt,
+	}
+}
+
+func (e *lineEditor) addHistory(line string) {
+	if n := len(e.history); n > 0 && e.history[n-1] == line {
+		return
+	}
+	e.history = append(e.history, line)
+}
+
+func (e *lineEditor) readLine() (string, error) {
+	var line []rune
+	pos := len(e.history)
+	e.redraw(line)
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\r\n")
+			return string(line), nil
+		case 3: // Ctrl-C
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", errInterrupted
+		case 4: // Ctrl-D
+			if len(line) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+		case 127, 8: // Backspace
+			if len(line) > 0 {
+				line = line[:len(line)-1]
+				e.redraw(line)
+			}
+		case 21: // Ctrl-U
+			line = line[:0]
+			e.redraw(line)
+		case '\t':
+			line = e.tab(line)
+		case 27: // ESC [ A/B
+			if b, _ := e.in.ReadByte(); b != '[' {
+				continue
+			}
+			switch b, _ := e.in.ReadByte(); b {
+			case 'A':
+				if pos > 0 {
+					pos--
+					line = []rune(e.history[pos])
+					e.redraw(line)
+				}
+			case 'B':
+				if pos < len(e.history) {
+					pos++
+					line = line[:0]
+					if pos < len(e.history) {
+						line = []rune(e.history[pos])
+					}
+					e.redraw(line)
+				}
+			}
+		default:
+			if r >= 32 && r != utf8.RuneError {
+				line = append(line, r)
+				fmt.Fprint(e.out, string(r))
+			}
+		}
+	}
+}
+
+func (e *lineEditor) tab(line []rune) []rune {
+	if e.complete == nil {
+		return line
+	}
+	head, candidates := e.complete(string(line))
+	switch len(candidates) {
+	case 0:
+		return line
+	case 1:
+		line = []rune(head + candidates[0])
+		if !strings.HasSuffix(candidates[0], ".") {
+			line = append(line, ' ')
+		}
+	default:
+		if prefix := head + commonPrefix(candidates); len(prefix) > len(string(line)) {
+			line = []rune(prefix)
+		} else {
+			fmt.Fprint(e.out, "\r\n"+strings.Join(candidates, "  ")+"\r\n")
+		}
+	}
+	e.redraw(line)
+	return line
+}
+
+func (e *lineEditor) redraw(line []rune) {
+	fmt.Fprint(e.out, "\r\033[K"+e.prompt+string(line))
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/guobinqiu/rpc"
+)
+
+const usage = `用法:
+  rpc call [flags] tcp://host:port Service.Method [args...]
+  rpc list [flags] tcp://host:port
+  rpc describe [flags] tcp://host:port Service[.Method]
+  rpc shell [flags] tcp://host:port
+
+参数是json，不是合法json的参数当作字符串
+list、describe和shell里的补全需要服务端调用了EnableReflection
+
+flags:
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+type options struct {
+	timeout    time.Duration
+	codec      string
+	tls        bool
+	caFile     string
+	certFile   string
+	keyFile    string
+	serverName string
+	insecure   bool
+}
+
+func (o *options) register(fs *flag.FlagSet) {
+	fs.DurationVar(&o.timeout, "timeout", 10*time.Second, "连接和调用的超时时间")
+	fs.StringVar(&o.codec, "codec", "json", "编码")
+	fs.BoolVar(&o.tls, "tls", false, "使用TLS连接")
+	fs.StringVar(&o.caFile, "cacert", "", "验证服务端证书的CA证书")
+	fs.StringVar(&o.certFile, "cert", "", "客户端证书，用于mTLS")
+	fs.StringVar(&o.keyFile, "key", "", "客户端私钥，用于mTLS")
+	fs.StringVar(&o.serverName, "servername", "", "覆盖TLS校验用的服务端名字")
+	fs.BoolVar(&o.insecure, "insecure", false, "不校验服务端证书")
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	var o options
+	fs := flag.NewFlagSet("rpc "+args[0], flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	o.register(fs)
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	var err error
+	switch args[0] {
+	case "call":
+		err = call(&o, fs.Args(), stdout)
+	case "list":
+		err = list(&o, fs.Args(), stdout)
+	case "describe":
+		err = describe(&o, fs.Args(), stdout)
+	case "shell":
+		err = runShell(&o, fs.Args(), stdin, stdout)
+	default:
+		fs.Usage()
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintln(stderr, "error:", formatError(err))
+		return 1
+	}
+	return 0
+}
+
+func formatError(err error) string {
+	var e *rpc.Error
+	if errors.As(err, &e) {
+		return e.Code.String() + ": " + e.Message
+	}
+	return err.Error()
+}
+
+func call(o *options, args []string, stdout io.Writer) error {
+	if len(args) < 2 {
+		return errors.New("需要地址和Service.Method")
+	}
+	serviceName, methodName, ok := splitMethod(args[1])
+	if !ok {
+		return fmt.Errorf("方法格式不对: %s", args[1])
+	}
+
+	client, err := dial(o, args[0])
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), o.timeout)
+	defer cancel()
+
+	out, err := client.CallContext(ctx, serviceName, methodName, parseArgs(args[2:]))
+	if err != nil {
+		return err
+	}
+	return printOut(stdout, out)
+}
+
+func list(o *options, args []string, stdout io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("需要地址")
+	}
+
+	client, err := dial(o, args[0])
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), o.timeout)
+	defer cancel()
+	return listServices(ctx, client, stdout)
+}
+
+func describe(o *options, args []string, stdout io.Writer) error {
+	if len(args) != 2 {
+		return errors.New("需要地址和Service或Service.Method")
+	}
+
+	client, err := dial(o, args[0])
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), o.timeout)
+	defer cancel()
+	return describeService(ctx, client, args[1], stdout)
+}
+
+func listServices(ctx context.Context, client *rpc.Client, stdout io.Writer) error {
+	names, err := rpc.Call1[[]string](ctx, client, rpc.ReflectionServiceName, "ListServices")
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		fmt.Fprintln(stdout, name)
+	}
+	return nil
+}
+
+func describeService(ctx context.Context, client *rpc.Client, name string, stdout io.Writer) error {
+	// 服务名本身可能带点，先当成服务名查
+	serviceName, methodName := name, ""
+	schema, err := rpc.Call1[*rpc.ServiceSchema](ctx, client, rpc.ReflectionServiceName, "Describe", serviceName)
+	if err != nil {
+		return err
+	}
+	if schema == nil {
+		var ok bool
+		if serviceName, methodName, ok = splitMethod(name); ok {
+			schema, err = rpc.Call1[*rpc.ServiceSchema](ctx, client, rpc.ReflectionServiceName, "Describe", serviceName)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if schema == nil {
+		return fmt.Errorf("服务没找到: %s", name)
+	}
+
+	if methodName == "" {
+		for _, m := range schema.Methods {
+			fmt.Fprintln(stdout, signature(m))
+		}
+		return nil
+	}
+
+	for _, m := range schema.Methods {
+		if m.Name == methodName {
+			fmt.Fprintln(stdout, signature(m))
+			return printJSON(stdout, map[string]any{
+				"input":  m.Input,
+				"output": m.Output,
+				"$defs":  schema.Defs,
+			})
+		}
+	}
+	return fmt.Errorf("方法没找到: %s", name)
+}
+
+func splitMethod(s string) (string, string, bool) {
+	i := strings.LastIndex(s, ".")
+	if i <= 0 || i == len(s)-1 {
+		return s, "", false
+	}
+	return s[:i], s[i+1:], true
+}
+
+func parseArgs(args []string) []any {
+	inArgs := make([]any, 0, len(args))
+	for _, arg := range args {
+		var v any
+		if err := json.Unmarshal([]byte(arg), &v); err != nil {
+			v = arg
+		}
+		inArgs = append(inArgs, v)
+	}
+	return inArgs
+}
+
+func printOut(w io.Writer, out *rpc.Out) error {
+	if out.Len() == 1 {
+		return printJSON(w, out.Get(0))
+	}
+	results := make([]any, 0, out.Len())
+	for i := 0; i < out.Len(); i++ {
+		results = append(results, out.Get(i))
+	}
+	return printJSON(w, results)
+}
+
+func printJSON(w io.Writer, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/guobinqiu/rpc"
+)
+
+type user struct {
+	Name string
+	Age  int
+}
+
+type Userservice struct{}
+
+func (s *Userservice) Add(a int, b int) int {
+	return a + b
+}
+
+func (s *Userservice) Hello(name string) string {
+	return "hello " + name
+}
+
+func (s *Userservice) Greet(u user) string {
+	return "hello " + u.Name
+}
+
+func startServer(t *testing.T) string {
+	server := rpc.NewServer()
+	server.Register(new(Userservice), "UserService")
+	server.EnableReflection()
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go server.ServeConn(conn)
+		}
+	}()
+	t.Cleanup(func() { l.Close() })
+	return "tcp://" + l.Addr().String()
+}
+
+func TestCall(t *testing.T) {
+	target := startServer(t)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"call", target, "UserService.Add", "1", "2"}, nil, &stdout, &stderr); code != 0 {
+		t.Fatal(code, stderr.String())
+	}
+	if strings.TrimSpace(stdout.String()) != "3" {
+		t.Error(stdout.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"call", "-timeout", "1s", target, "UserService.Hello", "guobin"}, nil, &stdout, &stderr); code != 0 {
+		t.Fatal(code, stderr.String())
+	}
+	if strings.TrimSpace(stdout.String()) != `"hello guobin"` {
+		t.Error(stdout.String())
+	}
+}
+
+func TestCallError(t *testing.T) {
+	target := startServer(t)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"call", target, "UserService.Nope"}, nil, &stdout, &stderr); code != 1 {
+		t.Error(code)
+	}
+	if !strings.HasPrefix(stderr.String(), "error: NotFound: ") {
+		t.Error(stderr.String())
+	}
+
+	stderr.Reset()
+	if code := run([]string{"call", "-codec", "nope", target, "UserService.Add", "1", "2"}, nil, &stdout, &stderr); code != 1 {
+		t.Error(code, stderr.String())
+	}
+}
+
+func TestCallHTTP(t *testing.T) {
+	server := rpc.NewServer()
+	server.Register(new(Userservice), "UserService")
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"call", ts.URL + "/rpc", "UserService.Add", "1", "2"}, nil, &stdout, &stderr); code != 0 {
+		t.Fatal(code, stderr.String())
+	}
+	if strings.TrimSpace(stdout.String()) != "3" {
+		t.Error(stdout.String())
+	}
+}
+
+func TestList(t *testing.T) {
+	target := startServer(t)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"list", target}, nil, &stdout, &stderr); code != 0 {
+		t.Fatal(code, stderr.String())
+	}
+	if stdout.String() != "UserService\n"+rpc.ReflectionServiceName+"\n" {
+		t.Error(stdout.String())
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	target := startServer(t)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"describe", target, "UserService"}, nil, &stdout, &stderr); code != 0 {
+		t.Fatal(code, stderr.String())
+	}
+	if stdout.String() != "Add(integer, integer) (integer)\nGreet(main.user) (string)\nHello(string) (string)\n" {
+		t.Error(stdout.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"describe", target, "UserService.Add"}, nil, &stdout, &stderr); code != 0 {
+		t.Fatal(code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), `"prefixItems"`) {
+		t.Error(stdout.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"describe", target, rpc.ReflectionServiceName}, nil, &stdout, &stderr); code != 0 {
+		t.Fatal(code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "ListServices() ([]string)") {
+		t.Error(stdout.String())
+	}
+}
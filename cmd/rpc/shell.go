@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/guobinqiu/rpc"
+)
+
+var shellCommands = []string{"list", "describe", "history", "help", "exit"}
+
+const shellHelp = `  Service.Method [args...]   调用方法，参数是json，tab可以补全方法名和参数模板
+  list                       列出服务
+  describe Service[.Method]  查看服务或方法
+  history                    调用历史和耗时
+  exit                       退出
+`
+
+type callRecord struct {
+	line     string
+	duration time.Duration
+	err      error
+}
+
+type shell struct {
+	o         *options
+	target    string
+	client    *rpc.Client
+	out       io.Writer
+	completer *completer
+	history   []callRecord
+}
+
+func runShell(o *options, args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("需要地址")
+	}
+
+	sh := &shell{
+		o:      o,
+		target: args[0],
+		out:    stdout,
+	}
+	if err := sh.connect(); err != nil {
+		return err
+	}
+	defer func() { sh.client.Close() }()
+	sh.loadSchemas()
+
+	fmt.Fprintf(stdout, "已连接 %s，输入help查看帮助\n", sh.target)
+
+	readLine := sh.lineReader(stdin)
+	for {
+		line, err := readLine()
+		if err == errInterrupted {
+			continue
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if line == "exit" || line == "quit" {
+			return nil
+		}
+		sh.exec(line)
+	}
+}
+
+// 终端下用行编辑器支持补全和历史，否则(比如管道)按行读
+func (sh *shell) lineReader(stdin io.Reader) func() (string, error) {
+	if f, ok := stdin.(*os.File); ok {
+		if restore, err := makeRaw(f.Fd()); err == nil {
+			restore()
+			editor := newLineEditor(f, sh.out, "rpc> ")
+			editor.complete = sh.completer.complete
+			return func() (string, error) {
+				// 只在读一行的时候进入raw模式，调用期间终端保持正常
+				restore, err := makeRaw(f.Fd())
+				if err != nil {
+					return "", err
+				}
+				line, err := editor.readLine()
+				restore()
+				if strings.TrimSpace(line) != "" {
+					editor.addHistory(strings.TrimSpace(line))
+				}
+				return line, err
+			}
+		}
+	}
+
+	scanner := bufio.NewScanner(stdin)
+	return func() (string, error) {
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return "", err
+			}
+			return "", io.EOF
+		}
+		return scanner.Text(), nil
+	}
+}
+
+func (sh *shell) connect() error {
+	client, err := dial(sh.o, sh.target)
+	if err != nil {
+		return err
+	}
+	sh.client = client
+	return nil
+}
+
+// 服务端没有开启反射的话就没有补全
+func (sh *shell) loadSchemas() {
+	ctx, cancel := context.WithTimeout(context.Background(), sh.o.timeout)
+	defer cancel()
+
+	var schemas []*rpc.ServiceSchema
+	names, err := rpc.Call1[[]string](ctx, sh.client, rpc.ReflectionServiceName, "ListServices")
+	if err == nil {
+		for _, name := range names {
+			schema, err := rpc.Call1[*rpc.ServiceSchema](ctx, sh.client, rpc.ReflectionServiceName, "Describe", name)
+			if err == nil && schema != nil {
+				schemas = append(schemas, schema)
+			}
+		}
+	}
+	sh.completer = newCompleter(schemas)
+}
+
+func (sh *shell) exec(line string) {
+	words := splitArgs(line)
+
+	ctx, cancel := context.WithTimeout(context.Background(), sh.o.timeout)
+	defer cancel()
+
+	var err error
+	switch words[0] {
+	case "help":
+		fmt.Fprint(sh.out, shellHelp)
+	case "history":
+		for i, r := range sh.history {
+			status := "ok"
+			if r.err != nil {
+				status = "error"
+			}
+			fmt.Fprintf(sh.out, "%4d  %s  %s  %v\n", i+1, r.line, status, r.duration)
+		}
+	case "list":
+		err = listServices(ctx, sh.client, sh.out)
+	case "describe":
+		if len(words) != 2 {
+			err = errors.New("需要Service或Service.Method")
+		} else {
+			err = describeService(ctx, sh.client, words[1], sh.out)
+		}
+	default:
+		err = sh.call(ctx, line, words)
+	}
+
+	if err != nil {
+		fmt.Fprintln(sh.out, "error:", formatError(err))
+	}
+	if connBroken(err) {
+		sh.client.Close()
+		if err := sh.connect(); err != nil {
+			fmt.Fprintln(sh.out, "error:", err)
+		}
+	}
+}
+
+// 超时、取消的调用会关掉连接，服务端断开或者网络出错时连接也不能用了，这些都要重新连一下
+func connBroken(err error) bool {
+	var ne net.Error
+	return errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, rpc.ErrShutdown) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.As(err, &ne)
+}
+
+func (sh *shell) call(ctx context.Context, line string, words []string) error {
+	serviceName, methodName, ok := splitMethod(words[0])
+	if !ok {
+		return fmt.Errorf("不认识的命令: %s", words[0])
+	}
+
+	start := time.Now()
+	out, err := sh.client.CallContext(ctx, serviceName, methodName, parseArgs(words[1:]))
+	duration := time.Since(start)
+	sh.history = append(sh.history, callRecord{line: line, duration: duration, err: err})
+	if err != nil {
+		return err
+	}
+
+	if out.Len() > 0 {
+		if err := printOut(sh.out, out); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(sh.out, "(%v)\n", duration)
+	return nil
+}
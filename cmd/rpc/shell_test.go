@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/guobinqiu/rpc"
+)
+
+func TestShell(t *testing.T) {
+	target := startServer(t)
+
+	stdin := strings.NewReader(`UserService.Add 1 2
+UserService.Greet {"Name": "guobin", "Age": 40}
+history
+list
+describe UserService.Add
+bogus
+exit
+UserService.Add 3 4
+`)
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"shell", target}, stdin, &stdout, &stderr); code != 0 {
+		t.Fatal(code, stderr.String())
+	}
+
+	out := stdout.String()
+	t.Log(out)
+	for _, s := range []string{
+		"3\n(",
+		`"hello guobin"`,
+		"   1  UserService.Add 1 2  ok",
+		`   2  UserService.Greet {"Name": "guobin", "Age": 40}  ok`,
+		rpc.ReflectionServiceName + "\n",
+		"Add(integer, integer) (integer)",
+		"error: 不认识的命令: bogus",
+	} {
+		if !strings.Contains(out, s) {
+			t.Error("missing", s)
+		}
+	}
+	if strings.Contains(out, "7\n") {
+		t.Error("should exit before last line")
+	}
+}
+
+func TestShellRedial(t *testing.T) {
+	server := rpc.NewServer()
+	server.Register(new(Userservice), "UserService")
+
+	// 第二次调用时服务端把连接断掉
+	var mu sync.Mutex
+	var conns []net.Conn
+	calls := 0
+	server.Use(func(next rpc.Handler) rpc.Handler {
+		return func(ctx context.Context, call *rpc.CallInfo) ([]any, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if call.ServiceName != "UserService" {
+				return next(ctx, call)
+			}
+			if calls++; calls == 2 {
+				for _, conn := range conns {
+					conn.Close()
+				}
+			}
+			return next(ctx, call)
+		}
+	})
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, conn)
+			mu.Unlock()
+			go server.ServeConn(conn)
+		}
+	}()
+
+	stdin := strings.NewReader("UserService.Add 1 2\nUserService.Add 2 3\nUserService.Add 3 4\n")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"shell", "tcp://" + l.Addr().String()}, stdin, &stdout, &stderr); code != 0 {
+		t.Fatal(code, stderr.String())
+	}
+
+	out := stdout.String()
+	t.Log(out)
+	if !strings.Contains(out, "3\n") || !strings.Contains(out, "error:") || !strings.Contains(out, "7\n") {
+		t.Error(out)
+	}
+}
+
+func testCompleter() *completer {
+	server := rpc.NewServer()
+	server.Register(new(Userservice), "UserService")
+	return newCompleter(server.Schemas())
+}
+
+func TestComplete(t *testing.T) {
+	c := testCompleter()
+
+	for _, tc := range []struct {
+		line       string
+		head       string
+		candidates []string
+	}{
+		{"", "", append(append([]string{}, shellCommands...), "UserService.Add", "UserService.Greet", "UserService.Hello")},
+		{"User", "", []string{"UserService.Add", "UserService.Greet", "UserService.Hello"}},
+		{"he", "", []string{"help"}},
+		{"describe U", "describe ", []string{"UserService", "UserService.Add", "UserService.Greet", "UserService.Hello"}},
+		{"UserService.Add ", "UserService.Add ", []string{"0"}},
+		{"UserService.Add 1 ", "UserService.Add 1 ", []string{"0"}},
+		{"UserService.Add 1 2 ", "UserService.Add 1 2 ", nil},
+		{"UserService.Greet ", "UserService.Greet ", []string{`{"Age":0,"Name":""}`}},
+		{"UserService.Hello ", "UserService.Hello ", []string{`""`}},
+	} {
+		head, candidates := c.complete(tc.line)
+		if head != tc.head || !reflect.DeepEqual(candidates, tc.candidates) {
+			t.Errorf("%q: got %q %q", tc.line, head, candidates)
+		}
+	}
+}
+
+func TestLineEditor(t *testing.T) {
+	c := testCompleter()
+	in := strings.NewReader("UserService.A\t1 2\r" + "xx\x7f\x7f\x1b[A\r" + "UserService.\t\r" + "\x03" + "\x04")
+	var out bytes.Buffer
+	e := newLineEditor(in, &out, "rpc> ")
+	e.complete = c.complete
+
+	line, err := e.readLine()
+	if err != nil || line != "UserService.Add 1 2" {
+		t.Fatalf("%q %v", line, err)
+	}
+	e.addHistory(line)
+
+	line, err = e.readLine()
+	if err != nil || line != "UserService.Add 1 2" {
+		t.Fatalf("%q %v", line, err)
+	}
+
+	line, err = e.readLine()
+	if err != nil || line != "UserService." {
+		t.Fatalf("%q %v", line, err)
+	}
+	if !strings.Contains(out.String(), "UserService.Add  UserService.Greet  UserService.Hello") {
+		t.Error(out.String())
+	}
+
+	if _, err = e.readLine(); err != errInterrupted {
+		t.Error(err)
+	}
+	if _, err = e.readLine(); err == nil {
+		t.Error("expected EOF")
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	got := splitArgs(`Svc.M  1 "a b" {"Name": "x y", "Tags": ["a", "b"]}	[1, 2]`)
+	want := []string{"Svc.M", "1", `"a b"`, `{"Name": "x y", "Tags": ["a", "b"]}`, "[1, 2]"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%q", got)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package main
+
+import "errors"
+
+func makeRaw(fd uintptr) (func(), error) {
+	return nil, errors.New("不支持的终端")
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+type method struct {
+	Name    string
+	Params  []field
+	Results []field
+}
+
+type field struct {
+	Name string
+	Type string
+}
+
+type importSpec struct {
+	Name string
+	Path string
+}
+
+func generate(dir, typeName, serviceName, clientName string) ([]byte, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	fset := token.NewFileSet()
+	var pkgName string
+	var methods []method
+	imports := make(map[string]importSpec)
+
+	for _, filename := range files {
+		if strings.HasSuffix(filename, "_test.go") {
+			continue
+		}
+		src, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		f, err := parser.ParseFile(fset, filename, src, 0)
+		if err != nil {
+			return nil, err
+		}
+		pkgName = f.Name.Name
+
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv == nil || !fn.Name.IsExported() || receiverName(fn.Recv) != typeName {
+				continue
+			}
+			if !supported(fn.Type) {
+				continue
+			}
+			m := method{Name: fn.Name.Name}
+			m.Params = fields(fset, fn.Type.Params, "arg")
+			// 服务端会自己传context，客户端不用传
+			if len(m.Params) > 0 && m.Params[0].Type == "context.Context" {
+				m.Params = m.Params[1:]
+			}
+			m.Results = fields(fset, fn.Type.Results, "")
+			// 服务端把最后一个error当作调用的错误返回，客户端从CallContext的err里拿
+			if n := len(m.Results); n > 0 && m.Results[n-1].Type == "error" {
+				m.Results = m.Results[:n-1]
+			}
+			methods = append(methods, m)
+			collectImports(f, fn.Type, imports)
+		}
+	}
+
+	if pkgName == "" {
+		return nil, errors.New("没有找到go文件")
+	}
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("类型%s没有可以调用的方法", typeName)
+	}
+
+	for i := range methods {
+		rename(methods[i].Params)
+	}
+
+	var specs []importSpec
+	for _, spec := range imports {
+		specs = append(specs, spec)
+	}
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Path < specs[j].Path })
+
+	var buf bytes.Buffer
+	err = clientTemplate.Execute(&buf, map[string]any{
+		"Package":     pkgName,
+		"Imports":     specs,
+		"ServiceName": serviceName,
+		"ClientName":  clientName,
+		"Methods":     methods,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+func receiverName(recv *ast.FieldList) string {
+	if len(recv.List) != 1 {
+		return ""
+	}
+	expr := recv.List[0].Type
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	if ident, ok := expr.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+// 函数、channel和可变参数没法通过rpc传递
+func supported(fn *ast.FuncType) bool {
+	ok := true
+	check := func(list *ast.FieldList) {
+		if list == nil {
+			return
+		}
+		for _, f := range list.List {
+			switch f.Type.(type) {
+			case *ast.FuncType, *ast.ChanType, *ast.Ellipsis:
+				ok = false
+			}
+		}
+	}
+	check(fn.Params)
+	check(fn.Results)
+	return ok
+}
+
+func fields(fset *token.FileSet, list *ast.FieldList, prefix string) []field {
+	var out []field
+	if list == nil {
+		return out
+	}
+	for _, f := range list.List {
+		var buf bytes.Buffer
+		printer.Fprint(&buf, fset, f.Type)
+		typ := buf.String()
+
+		if len(f.Names) == 0 {
+			out = append(out, field{Name: prefix + strconv.Itoa(len(out)), Type: typ})
+			continue
+		}
+		for _, n := range f.Names {
+			name := n.Name
+			if name == "_" {
+				name = prefix + strconv.Itoa(len(out))
+			}
+			out = append(out, field{Name: name, Type: typ})
+		}
+	}
+	return out
+}
+
+// 参数名不能和生成代码里用到的变量重名
+func rename(params []field) {
+	used := map[string]bool{"ctx": true, "c": true, "out": true, "err": true, "rpc": true, "context": true}
+	for i := range params {
+		name := params[i].Name
+		for used[name] || isResultName(name) {
+			name += "_"
+		}
+		used[name] = true
+		params[i].Name = name
+	}
+}
+
+func isResultName(name string) bool {
+	if len(name) < 2 || name[0] != 'r' {
+		return false
+	}
+	_, err := strconv.Atoi(name[1:])
+	return err == nil
+}
+
+func collectImports(f *ast.File, fn *ast.FuncType, imports map[string]importSpec) {
+	ast.Inspect(fn, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		ident, ok := sel.X.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		for _, imp := range f.Imports {
+			p, _ := strconv.Unquote(imp.Path.Value)
+			if p == "context" {
+				continue
+			}
+			if imp.Name != nil && imp.Name.Name == ident.Name {
+				imports[p] = importSpec{Name: imp.Name.Name, Path: p}
+			} else if imp.Name == nil && path.Base(p) == ident.Name {
+				imports[p] = importSpec{Path: p}
+			}
+		}
+		return false
+	})
+}
+
+var clientTemplate = template.Must(template.New("client").Parse(`// Code generated by rpcgen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"context"
+{{range .Imports}}
+	{{if .Name}}{{.Name}} {{end}}"{{.Path}}"
+{{- end}}
+
+	"github.com/guobinqiu/rpc"
+)
+
+type {{.ClientName}} struct {
+	c rpc.Caller
+}
+
+func New{{.ClientName}}(c rpc.Caller) *{{.ClientName}} {
+	return &{{.ClientName}}{c: c}
+}
+{{range .Methods}}{{$m := .}}
+func (c *{{$.ClientName}}) {{.Name}}(ctx context.Context{{range .Params}}, {{.Name}} {{.Type}}{{end}}) ({{range $i, $r := .Results}}{{$r.Type}}, {{end}}error) {
+	{{- range $i, $r := .Results}}
+	var r{{$i}} {{$r.Type}}
+	{{- end}}
+	{{if .Results}}out{{else}}_{{end}}, err := c.c.CallContext(ctx, "{{$.ServiceName}}", "{{.Name}}", []any{ {{- range $i, $p := .Params}}{{if $i}}, {{end}}{{$p.Name}}{{end -}} })
+	if err != nil {
+		return {{range $i, $r := .Results}}r{{$i}}, {{end}}err
+	}
+	{{- range $i, $r := .Results}}
+	if err := out.Decode({{$i}}, &r{{$i}}); err != nil {
+		return {{range $j, $_ := $m.Results}}r{{$j}}, {{end}}err
+	}
+	{{- end}}
+	return {{range $i, $r := .Results}}r{{$i}}, {{end}}nil
+}
+{{end}}`))
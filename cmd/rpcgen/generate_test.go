@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateExample(t *testing.T) {
+	src, err := generate("../../example/service", "Userservice", "UserService", "UserServiceClient")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile("../../example/service/userservice_client.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, want) {
+		t.Error("example/service/userservice_client.go is out of date, run go generate")
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	src, err := generate("testdata/svc", "Svc", "Svc", "SvcClient")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(string(src))
+
+	if _, err := parser.ParseFile(token.NewFileSet(), "", src, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	code := string(src)
+	for _, s := range []string{
+		`t "time"`,
+		"func (c *SvcClient) At(ctx context.Context, ctx_ int, arg1 string, when t.Time) (t.Time, error)",
+		"func (c *SvcClient) Pair(ctx context.Context, arg0 int) (int, string, error)",
+		"func (c *SvcClient) Nothing(ctx context.Context) error",
+		`_, err := c.c.CallContext(ctx, "Svc", "Nothing", []any{})`,
+		"func (c *SvcClient) WithContext(ctx context.Context, id int64) (string, error)",
+		"func (c *SvcClient) Div(ctx context.Context, a int, b int) (int, error)",
+		"func (c *SvcClient) Check(ctx context.Context, n int) error",
+	} {
+		if !strings.Contains(code, s) {
+			t.Error("missing", s)
+		}
+	}
+	for _, s := range []string{"Func", "Variadic", "unexported"} {
+		if strings.Contains(code, ") "+s+"(") {
+			t.Error("unexpected", s)
+		}
+	}
+}
+
+// 生成的代码放到testdata/svc的副本里编译
+func TestGenerateCompiles(t *testing.T) {
+	if testing.Short() {
+		t.Skip("要调用go build")
+	}
+	src, err := generate("testdata/svc", "Svc", "Svc", "SvcClient")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := filepath.Abs("../..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, err := os.ReadFile("testdata/svc/svc.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":        "module svc\n\ngo 1.21\n\nrequire github.com/guobinqiu/rpc v0.0.0\n\nreplace github.com/guobinqiu/rpc => " + root + "\n",
+		"svc.go":        string(svc),
+		"svc_client.go": string(src),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command("go", "build", "./...")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+}
+
+func TestGenerateTypeNotFound(t *testing.T) {
+	if _, err := generate("testdata/svc", "Nope", "Nope", "NopeClient"); err == nil {
+		t.Error("expected error")
+	}
+}
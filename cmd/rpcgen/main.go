@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	typeName   = flag.String("type", "", "服务的类型名，必填")
+	name       = flag.String("name", "", "服务注册的名字，默认和类型名相同")
+	clientName = flag.String("client", "", "生成的客户端类型名，默认是<name>Client")
+	output     = flag.String("output", "", "输出文件，默认是<type>_client.go")
+	dir        = flag.String("dir", ".", "服务所在的包目录")
+)
+
+func main() {
+	flag.Parse()
+	if *typeName == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *name == "" {
+		*name = *typeName
+	}
+	if *clientName == "" {
+		*clientName = *name + "Client"
+	}
+	if *output == "" {
+		*output = filepath.Join(*dir, strings.ToLower(*typeName)+"_client.go")
+	}
+
+	src, err := generate(*dir, *typeName, *name, *clientName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "rpcgen:", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(*output, src, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "rpcgen:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,40 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	t "time"
+)
+
+type Svc struct{}
+
+func (s *Svc) At(ctx int, _ string, when t.Time) t.Time {
+	return when
+}
+
+func (s Svc) Pair(int) (int, string) {
+	return 0, ""
+}
+
+func (s *Svc) Nothing() {}
+
+func (s *Svc) Div(a, b int) (int, error) {
+	if b == 0 {
+		return 0, errors.New("除数不能是0")
+	}
+	return a / b, nil
+}
+
+func (s *Svc) Check(n int) error {
+	return nil
+}
+
+func (s *Svc) WithContext(ctx context.Context, id int64) string {
+	return ""
+}
+
+func (s *Svc) Func(f func()) {}
+
+func (s *Svc) Variadic(nums ...int) {}
+
+func (s *Svc) unexported() {}
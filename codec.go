@@ -0,0 +1,67 @@
+package rpc
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+type Encoder interface {
+	Encode(v any) error
+}
+
+type Decoder interface {
+	Decode(v any) error
+}
+
+// 服务端按json的通用类型(float64, string, []any, map[string]any)匹配参数，
+// 所以Decoder解出来的InArgs也要是这些类型
+type Codec interface {
+	Name() string
+	NewEncoder(w io.Writer) Encoder
+	NewDecoder(r io.Reader) Decoder
+	// 把解码出来的通用类型转换成具体类型，dst是指针
+	Convert(src any, dst any) error
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string {
+	return "json"
+}
+
+func (jsonCodec) NewEncoder(w io.Writer) Encoder {
+	return json.NewEncoder(w)
+}
+
+func (jsonCodec) NewDecoder(r io.Reader) Decoder {
+	return json.NewDecoder(r)
+}
+
+func (jsonCodec) Convert(src any, dst any) error {
+	b, err := json.Marshal(src)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, dst)
+}
+
+var JSONCodec Codec = jsonCodec{}
+
+var (
+	codecs   = map[string]Codec{JSONCodec.Name(): JSONCodec}
+	codecsMu sync.RWMutex
+)
+
+func RegisterCodec(codec Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[codec.Name()] = codec
+}
+
+func GetCodec(name string) (Codec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	codec, ok := codecs[name]
+	return codec, ok
+}
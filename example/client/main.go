@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/guobinqiu/rpc"
+	"github.com/guobinqiu/rpc/example/service"
 )
 
 func main() {
@@ -15,5 +17,12 @@ func main() {
 	out, _ := client.Call("UserService", "Add", []interface{}{1, 2})
 	fmt.Println(out.Get(0))
 
+	userService := service.NewUserServiceClient(client)
+	sum, err := userService.Add(context.Background(), 1, 2)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(sum)
+
 	client.Close()
 }
@@ -4,17 +4,13 @@ import (
 	"net"
 
 	"github.com/guobinqiu/rpc"
+	"github.com/guobinqiu/rpc/example/service"
 )
 
-type Userservice struct{}
-
-func (s *Userservice) Add(a, b int) int {
-	return a + b
-}
-
 func main() {
 	server := rpc.NewServer()
-	server.Register(new(Userservice), "UserService")
+	server.Register(new(service.Userservice), "UserService")
+	server.EnableReflection()
 
 	listener, err := net.Listen("tcp", ":3456")
 	if err != nil {
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"errors"
+	"time"
+)
+
+//go:generate go run github.com/guobinqiu/rpc/cmd/rpcgen -type Userservice -name UserService
+
+type User struct {
+	ID       int64
+	Name     string
+	Birthday time.Time
+}
+
+type Userservice struct{}
+
+func (s *Userservice) Add(a, b int) int {
+	return a + b
+}
+
+func (s *Userservice) Div(a, b int) (int, error) {
+	if b == 0 {
+		return 0, errors.New("除数不能是0")
+	}
+	return a / b, nil
+}
+
+func (s *Userservice) GetUserById(id int64) *User {
+	return &User{
+		ID:       id,
+		Name:     "Guobin",
+		Birthday: time.Date(1983, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
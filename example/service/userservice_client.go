@@ -0,0 +1,53 @@
+// Code generated by rpcgen. DO NOT EDIT.
+
+package service
+
+import (
+	"context"
+
+	"github.com/guobinqiu/rpc"
+)
+
+type UserServiceClient struct {
+	c rpc.Caller
+}
+
+func NewUserServiceClient(c rpc.Caller) *UserServiceClient {
+	return &UserServiceClient{c: c}
+}
+
+func (c *UserServiceClient) Add(ctx context.Context, a int, b int) (int, error) {
+	var r0 int
+	out, err := c.c.CallContext(ctx, "UserService", "Add", []any{a, b})
+	if err != nil {
+		return r0, err
+	}
+	if err := out.Decode(0, &r0); err != nil {
+		return r0, err
+	}
+	return r0, nil
+}
+
+func (c *UserServiceClient) Div(ctx context.Context, a int, b int) (int, error) {
+	var r0 int
+	out, err := c.c.CallContext(ctx, "UserService", "Div", []any{a, b})
+	if err != nil {
+		return r0, err
+	}
+	if err := out.Decode(0, &r0); err != nil {
+		return r0, err
+	}
+	return r0, nil
+}
+
+func (c *UserServiceClient) GetUserById(ctx context.Context, id int64) (*User, error) {
+	var r0 *User
+	out, err := c.c.CallContext(ctx, "UserService", "GetUserById", []any{id})
+	if err != nil {
+		return r0, err
+	}
+	if err := out.Decode(0, &r0); err != nil {
+		return r0, err
+	}
+	return r0, nil
+}
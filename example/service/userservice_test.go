@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/guobinqiu/rpc"
+)
+
+func TestUserServiceClient(t *testing.T) {
+	server := rpc.NewServer()
+	server.Register(new(Userservice), "UserService")
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go server.ServeConn(conn)
+		}
+	}()
+
+	client, _ := rpc.Dial("tcp", l.Addr().String())
+	userService := NewUserServiceClient(client)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	sum, err := userService.Add(ctx, 1, 2)
+	if err != nil {
+		t.Error(err)
+	}
+	if sum != 3 {
+		t.Error(sum)
+	}
+
+	q, err := userService.Div(ctx, 7, 2)
+	if err != nil || q != 3 {
+		t.Error(q, err)
+	}
+	if _, err := userService.Div(ctx, 1, 0); err == nil || err.Error() != "除数不能是0" {
+		t.Error(err)
+	}
+
+	u, err := userService.GetUserById(ctx, 1)
+	if err != nil {
+		t.Error(err)
+	}
+	if u.ID != 1 || u.Name != "Guobin" || u.Birthday.Year() != 1983 {
+		t.Error(u)
+	}
+
+	client.Close()
+	l.Close()
+}
@@ -0,0 +1,219 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// 和WebSocket的消息一样，最大16MB
+const httpMaxBodySize = 16 << 20
+
+// POST /rpc，body是{"ServiceName", "MethodName", "InArgs"}
+// POST /rpc/{Service}/{Method}，body是参数数组
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "只支持POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, httpMaxBodySize)
+	var out io.Writer = w
+	if m := s.opts.metrics; m != nil {
+		r.Body = io.NopCloser(&meteredReader{r.Body, m, SideServer})
+		out = &meteredWriter{w, m, SideServer}
+	}
+
+	var p param
+	if err := s.readHTTPRequest(r, &p); err != nil {
+		s.logDecodeError(withPeer(r.Context(), newHTTPPeer(r)), err)
+		if tooLarge(err) {
+			http.Error(w, "请求太大", http.StatusRequestEntityTooLarge)
+			return
+		}
+		p.setError(CodeInvalidArgument, "请求格式不对: "+err.Error())
+	} else {
+		p.Metadata = withHeaderMetadata(p.Metadata, r)
+		s.call(withPeer(r.Context(), newHTTPPeer(r)), &p)
+	}
+
+	w.Header().Set("Content-Type", contentType(s.opts.codec))
+	if ms, err := strconv.Atoi(p.Metadata.Get(retryAfterKey)); err == nil {
+		w.Header().Set("Retry-After", strconv.Itoa((ms+999)/1000))
+	}
+	w.WriteHeader(httpStatus(p.Code))
+	s.opts.codec.NewEncoder(out).Encode(&p)
+}
+
+func (s *Server) readHTTPRequest(r *http.Request, p *param) error {
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	n := len(segments)
+	if n == 0 || segments[n-1] == "" || segments[n-1] == "rpc" {
+		return s.opts.codec.NewDecoder(r.Body).Decode(p)
+	}
+	if n < 2 {
+		return fmt.Errorf("路径不对: %s", r.URL.Path)
+	}
+
+	p.ServiceName = segments[n-2]
+	p.MethodName = segments[n-1]
+	if err := s.opts.codec.NewDecoder(r.Body).Decode(&p.InArgs); err != nil && err != io.EOF {
+		return err
+	}
+	if p.InArgs == nil {
+		p.InArgs = []any{}
+	}
+	return nil
+}
+
+func tooLarge(err error) bool {
+	var e *http.MaxBytesError
+	return errors.As(err, &e)
+}
+
+// Authorization头当作metadata，请求里已经有的话不覆盖
+func withHeaderMetadata(md Metadata, r *http.Request) Metadata {
+	md = md.lower()
+	if auth := r.Header.Get("Authorization"); auth != "" && md.Get("authorization") == "" {
+		if md == nil {
+			md = Metadata{}
+		}
+		md["authorization"] = []string{auth}
+	}
+	return md
+}
+
+// 响应不是rpc的格式时按状态码转换，比如代理或者请求太大，只有网关出错才可以重试
+func httpCode(status int) Code {
+	switch status {
+	case http.StatusUnauthorized:
+		return CodeUnauthenticated
+	case http.StatusForbidden:
+		return CodePermissionDenied
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusRequestEntityTooLarge, http.StatusTooManyRequests:
+		return CodeResourceExhausted
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return CodeUnavailable
+	}
+	switch {
+	case status >= 400 && status < 500:
+		return CodeInvalidArgument
+	case status >= 500:
+		return CodeInternal
+	}
+	return CodeUnknown
+}
+
+func httpStatus(code Code) int {
+	switch code {
+	case CodeOK:
+		return http.StatusOK
+	case CodeInvalidArgument:
+		return http.StatusBadRequest
+	case CodeNotFound:
+		return http.StatusNotFound
+	case CodeUnavailable:
+		return http.StatusServiceUnavailable
+	case CodeUnauthenticated:
+		return http.StatusUnauthorized
+	case CodePermissionDenied:
+		return http.StatusForbidden
+	case CodeResourceExhausted:
+		return http.StatusTooManyRequests
+	}
+	return http.StatusInternalServerError
+}
+
+func contentType(codec Codec) string {
+	return "application/" + codec.Name()
+}
+
+// url是完整的地址，比如http://127.0.0.1:3456/rpc
+func DialHTTP(url string, opts ...DialOption) (*Client, error) {
+	o := newDialOptions(opts)
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("不支持的地址: %s", url)
+	}
+	httpClient := o.httpClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		transport: &httpTransport{
+			url:     url,
+			client:  httpClient,
+			codec:   o.codec,
+			metrics: o.metrics,
+		},
+		codec:        o.codec,
+		retry:        newRetrier(o),
+		breakers:     newBreakers(o.breaker),
+		metrics:      o.metrics,
+		tracer:       o.tracer,
+		interceptors: o.interceptors,
+		signer:       o.signer,
+	}, nil
+}
+
+type httpTransport struct {
+	url     string
+	client  *http.Client
+	codec   Codec
+	metrics Metrics
+}
+
+func (t *httpTransport) roundTrip(ctx context.Context, req *param, resp *param) error {
+	var body bytes.Buffer
+	if err := t.codec.NewEncoder(&body).Encode(req); err != nil {
+		return err
+	}
+
+	if t.metrics != nil {
+		t.metrics.BytesTransferred(SideClient, 0, body.Len())
+	}
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, &body)
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", contentType(t.codec))
+
+	res, err := t.client.Do(r)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	defer res.Body.Close()
+
+	var in io.Reader = res.Body
+	if t.metrics != nil {
+		in = &meteredReader{in, t.metrics, SideClient}
+	}
+	if err := t.codec.NewDecoder(in).Decode(resp); err != nil {
+		return &Error{Code: httpCode(res.StatusCode), Message: "http " + res.Status}
+	}
+	return nil
+}
+
+func (t *httpTransport) broken() bool {
+	return false
+}
+
+func (t *httpTransport) rawConn() net.Conn {
+	return nil
+}
+
+func (t *httpTransport) close() error {
+	return nil
+}
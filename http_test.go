@@ -0,0 +1,197 @@
+package rpc
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDialHTTP(t *testing.T) {
+	server := NewServer()
+	server.Register(new(Userservice), "UserService")
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	client, err := DialHTTP(ts.URL + "/rpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := client.Call("UserService", "Add", []interface{}{1, 2})
+	if err != nil {
+		t.Error(err)
+	}
+	if out.Get(0) != float64(3) {
+		t.Error(out.Get(0))
+	}
+
+	u := user{Name: "Guobin", Age: 40}
+	out, err = client.Call("UserService", "GrowUpStruct", []interface{}{u})
+	if err != nil {
+		t.Error(err)
+	}
+	var grown user
+	out.Decode(0, &grown)
+	if grown.Age != 41 {
+		t.Error(grown)
+	}
+
+	_, err = client.Call("UserService", "GetUserByIdd", []interface{}{1})
+	if ErrorCode(err) != CodeNotFound {
+		t.Error(err)
+	}
+
+	_, err = client.Call("UserService", "GetUserById", []interface{}{1, 2})
+	if ErrorCode(err) != CodeInvalidArgument {
+		t.Error(err)
+	}
+
+	client.Close()
+}
+
+func TestServeHTTPPath(t *testing.T) {
+	server := NewServer()
+	server.Register(new(Userservice), "UserService")
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	for _, tc := range []struct {
+		path   string
+		body   string
+		status int
+		code   Code
+	}{
+		{"/rpc/UserService/Add", "[1, 2]", http.StatusOK, CodeOK},
+		{"/rpc/UserService/EmptyIn", "", http.StatusOK, CodeOK},
+		{"/rpc", `{"ServiceName": "UserService", "MethodName": "Add", "InArgs": [1, 2]}`, http.StatusOK, CodeOK},
+		{"/rpc/UserServicee/Add", "[1, 2]", http.StatusNotFound, CodeNotFound},
+		{"/rpc/UserService/Add", "[1]", http.StatusBadRequest, CodeInvalidArgument},
+		{"/rpc/UserService/Add", "{", http.StatusBadRequest, CodeInvalidArgument},
+	} {
+		res, err := http.Post(ts.URL+tc.path, "application/json", strings.NewReader(tc.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var p param
+		json.NewDecoder(res.Body).Decode(&p)
+		res.Body.Close()
+
+		if res.StatusCode != tc.status || p.Code != tc.code {
+			t.Errorf("%s %s: %d %v %s", tc.path, tc.body, res.StatusCode, p.Code, p.Error)
+		}
+	}
+
+	res, err := http.Get(ts.URL + "/rpc/UserService/Add")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusMethodNotAllowed {
+		t.Error(res.StatusCode)
+	}
+}
+
+func TestHTTPBodyTooLarge(t *testing.T) {
+	server := NewServer()
+	server.Register(new(Userservice), "UserService")
+	for _, h := range []http.Handler{server, server.JSONRPCHandler()} {
+		ts := httptest.NewServer(h)
+		body := `{"jsonrpc": "2.0", "method": "UserService.Sum", "id": 1, "params": [[` + strings.Repeat("1,", httpMaxBodySize/2) + `1]]}`
+		res, err := http.Post(ts.URL+"/rpc", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusRequestEntityTooLarge {
+			t.Error(res.Status)
+		}
+		ts.Close()
+	}
+}
+
+// 响应不是rpc的格式时按状态码转换错误码，只有网关的错误会重试
+func TestDialHTTPStatus(t *testing.T) {
+	for status, code := range map[int]Code{
+		http.StatusRequestEntityTooLarge: CodeResourceExhausted,
+		http.StatusBadRequest:            CodeInvalidArgument,
+		http.StatusMethodNotAllowed:      CodeInvalidArgument,
+		http.StatusUnauthorized:          CodeUnauthenticated,
+		http.StatusForbidden:             CodePermissionDenied,
+		http.StatusNotFound:              CodeNotFound,
+		http.StatusInternalServerError:   CodeInternal,
+		http.StatusBadGateway:            CodeUnavailable,
+		http.StatusServiceUnavailable:    CodeUnavailable,
+		http.StatusGatewayTimeout:        CodeUnavailable,
+	} {
+		hits := 0
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits++
+			http.Error(w, "proxy error", status)
+		}))
+		client, _ := DialHTTP(ts.URL, WithRetryPolicy("UserService", RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}))
+		client.retry.learn("UserService", "Add", true)
+		_, err := client.Call("UserService", "Add", []interface{}{1, 2})
+		if ErrorCode(err) != code {
+			t.Error(status, err)
+		}
+		if want := map[bool]int{true: 3, false: 1}[code == CodeUnavailable]; hits != want {
+			t.Error(status, hits)
+		}
+		ts.Close()
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	server := NewServer()
+	server.Register(new(Userservice), "UserService")
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go server.ServeConn(conn)
+		}
+	}()
+
+	client, _ := Dial("tcp", l.Addr().String())
+	_, err := client.Call("UserServicee", "GetUserById", []interface{}{1})
+	if ErrorCode(err) != CodeNotFound {
+		t.Error(err)
+	}
+	_, err = client.Call("UserService", "GetUserById", []interface{}{"1"})
+	if ErrorCode(err) != CodeInvalidArgument {
+		t.Error(err)
+	}
+	t.Log(ErrorCode(err), err)
+
+	client.Close()
+	l.Close()
+}
+
+func TestOpenAPI(t *testing.T) {
+	server := NewServer()
+	server.Register(new(Userservice), "UserService")
+
+	doc := server.OpenAPI()
+	b, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paths := doc["paths"].(map[string]any)
+	if _, ok := paths["/rpc/UserService/Add"]; !ok {
+		t.Error(paths)
+	}
+	if !strings.Contains(string(b), `"$ref":"#/components/schemas/rpc.user"`) {
+		t.Error(string(b))
+	}
+	schemas := doc["components"].(map[string]any)["schemas"].(map[string]*JSONSchema)
+	if _, ok := schemas["rpc.user"]; !ok {
+		t.Error(schemas)
+	}
+}
@@ -0,0 +1,61 @@
+package rpc
+
+import "context"
+
+// 一次调用的描述，拦截器可以读也可以改，客户端和服务端共用
+type CallInfo struct {
+	ServiceName string
+	MethodName  string
+	// 解码后还没有按方法的参数类型转换的参数
+	InArgs []any
+	// 客户端是要发出去的metadata，服务端是收到的metadata，拦截器可以修改
+	Metadata Metadata
+	// 没有对端信息时为nil，客户端总是nil
+	Peer *Peer
+}
+
+// 返回方法的返回值，返回*Error可以带上错误码，其他错误当作CodeUnknown
+type Handler func(ctx context.Context, call *CallInfo) ([]any, error)
+
+type Interceptor func(next Handler) Handler
+
+// 先Use的在外层。拦截器可以不调用next直接返回，也可以修改参数或者返回值
+func (s *Server) Use(interceptors ...Interceptor) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.interceptors = append(s.interceptors, interceptors...)
+}
+
+func (s *Server) handler() Handler {
+	s.mu.Lock()
+	interceptors := s.interceptors
+	s.mu.Unlock()
+
+	h := Handler(s.invoke)
+	if s.opts.authorizer != nil {
+		h = s.authorize(h)
+	}
+	if s.opts.authenticator != nil {
+		h = s.authenticate(h)
+	}
+	if s.limiter != nil {
+		h = s.limit(h)
+	}
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		h = interceptors[i](h)
+	}
+	return h
+}
+
+// 客户端发出一次调用，拦截器里调用next才会真正发出去
+type Invoker func(ctx context.Context, call *CallInfo) (*Out, error)
+
+// 可以在编码前看到和修改参数，在解码后看到返回值和错误，也可以不调用next直接返回，比如测试里mock
+type ClientInterceptor func(next Invoker) Invoker
+
+func chainInvoker(interceptors []ClientInterceptor, invoke Invoker) Invoker {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		invoke = interceptors[i](invoke)
+	}
+	return invoke
+}
@@ -0,0 +1,111 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+func pipeClient(server *Server, opts ...DialOption) *Client {
+	serverConn, clientConn := net.Pipe()
+	go server.ServeConn(serverConn)
+	return DialConn(clientConn, opts...)
+}
+
+func TestInterceptorOrder(t *testing.T) {
+	server := NewServer()
+	server.Register(new(Userservice), "UserService")
+
+	var trace []string
+	record := func(name string) Interceptor {
+		return func(next Handler) Handler {
+			return func(ctx context.Context, call *CallInfo) ([]any, error) {
+				trace = append(trace, name+">")
+				out, err := next(ctx, call)
+				trace = append(trace, "<"+name)
+				return out, err
+			}
+		}
+	}
+	server.Use(record("a"), record("b"))
+	server.Use(record("c"))
+
+	client := pipeClient(server)
+	defer client.Close()
+	if _, err := client.Call("UserService", "Add", []interface{}{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(trace, " "); got != "a> b> c> <c <b <a" {
+		t.Error(got)
+	}
+}
+
+func TestInterceptorShortCircuit(t *testing.T) {
+	server := NewServer()
+	server.Register(new(Userservice), "UserService")
+	called := false
+	server.Use(func(next Handler) Handler {
+		return func(ctx context.Context, call *CallInfo) ([]any, error) {
+			if call.MethodName == "Add" {
+				return nil, &Error{Code: CodeInvalidArgument, Message: "不许加"}
+			}
+			if call.MethodName == "Sum" {
+				return nil, errors.New("boom")
+			}
+			called = true
+			return next(ctx, call)
+		}
+	})
+
+	client := pipeClient(server)
+	defer client.Close()
+
+	_, err := client.Call("UserService", "Add", []interface{}{1, 2})
+	if ErrorCode(err) != CodeInvalidArgument || err.Error() != "不许加" {
+		t.Error(err)
+	}
+	_, err = client.Call("UserService", "Sum", []interface{}{[]int{1}})
+	if ErrorCode(err) != CodeUnknown || err.Error() != "boom" {
+		t.Error(err)
+	}
+	if called {
+		t.Error("next should not be called")
+	}
+
+	// 不存在的方法也会经过拦截器
+	_, err = client.Call("UserService", "Nope", []interface{}{})
+	if ErrorCode(err) != CodeNotFound || !called {
+		t.Error(err, called)
+	}
+}
+
+func TestInterceptorMutate(t *testing.T) {
+	server := NewServer()
+	server.Register(new(Userservice), "UserService")
+	server.Use(func(next Handler) Handler {
+		return func(ctx context.Context, call *CallInfo) ([]any, error) {
+			if call.Peer == nil || call.Peer.Addr == nil {
+				t.Error("missing peer")
+			}
+			call.InArgs = []any{call.InArgs[0], float64(100)}
+			out, err := next(ctx, call)
+			if err != nil {
+				return nil, err
+			}
+			return append(out, "wrapped"), nil
+		}
+	})
+
+	client := pipeClient(server)
+	defer client.Close()
+
+	out, err := client.Call("UserService", "Add", []interface{}{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != 2 || out.Get(0) != float64(101) || out.Get(1) != "wrapped" {
+		t.Error(out.Get(0), out.Get(1))
+	}
+}
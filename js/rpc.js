@@ -0,0 +1,82 @@
+// 浏览器里用的参考客户端，服务端用server.WebSocketHandler()
+//
+//   const client = await new RPCClient("ws://127.0.0.1:8080/ws").connect();
+//   const [sum] = await client.call("UserService", "Add", 1, 2);
+//
+// 同一个连接上的请求是按顺序处理的，所以响应也按请求的顺序返回
+
+export class RPCError extends Error {
+  constructor(code, message) {
+    super(message);
+    this.name = "RPCError";
+    this.code = code;
+  }
+}
+
+export class RPCClient {
+  constructor(url) {
+    this.url = url;
+    this.ws = null;
+    this.pending = [];
+  }
+
+  connect() {
+    return new Promise((resolve, reject) => {
+      const ws = new WebSocket(this.url);
+      ws.onopen = () => resolve(this);
+      ws.onerror = () => reject(new Error("websocket连接失败: " + this.url));
+      ws.onmessage = (event) => this._onMessage(event.data);
+      ws.onclose = () => this._rejectAll(new Error("连接已关闭"));
+      this.ws = ws;
+    });
+  }
+
+  // 返回值是OutArgs数组
+  call(serviceName, methodName, ...inArgs) {
+    return new Promise((resolve, reject) => {
+      if (!this.ws || this.ws.readyState !== WebSocket.OPEN) {
+        reject(new Error("连接没有打开"));
+        return;
+      }
+      this.pending.push({ resolve, reject });
+      this.ws.send(JSON.stringify({
+        ServiceName: serviceName,
+        MethodName: methodName,
+        InArgs: inArgs,
+      }));
+    });
+  }
+
+  close() {
+    if (this.ws) {
+      this.ws.close();
+    }
+  }
+
+  _onMessage(data) {
+    const p = this.pending.shift();
+    if (!p) {
+      return;
+    }
+    let msg;
+    try {
+      msg = JSON.parse(data);
+    } catch (e) {
+      p.reject(e);
+      return;
+    }
+    if (msg.Error) {
+      p.reject(new RPCError(msg.Code, msg.Error));
+    } else {
+      p.resolve(msg.OutArgs || []);
+    }
+  }
+
+  _rejectAll(err) {
+    const pending = this.pending;
+    this.pending = [];
+    for (const p of pending) {
+      p.reject(err);
+    }
+  }
+}
@@ -0,0 +1,226 @@
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const (
+	jsonrpcParseError     = -32700
+	jsonrpcInvalidRequest = -32600
+	jsonrpcMethodNotFound = -32601
+	jsonrpcInvalidParams  = -32602
+	jsonrpcInternalError  = -32603
+	jsonrpcServerError    = -32000
+)
+
+type jsonrpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type jsonrpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *jsonrpcError   `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type jsonrpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+var jsonrpcNullID = json.RawMessage("null")
+
+type httpRequestKey struct{}
+
+// JSON-RPC 2.0，method是"Service.Method"，params只支持数组
+// 没有返回值时result是null，一个返回值时是这个值，多个返回值时是数组
+func (s *Server) ServeJSONRPC(conn net.Conn) {
+	ctx := s.connContext(withPeer(context.Background(), newPeer(conn, s.opts.handshakeTimeout)))
+	conn = meterConn(conn, s.opts.metrics, SideServer)
+	defer conn.Close()
+
+	decoder := json.NewDecoder(bufio.NewReader(conn))
+	encoder := json.NewEncoder(conn)
+
+	s.logConn(ctx, "rpc connection opened")
+	defer s.logConn(ctx, "rpc connection closed")
+
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			if !closedConnError(err) {
+				s.logDecodeError(ctx, err)
+			}
+			if err != io.EOF {
+				if _, ok := err.(*json.SyntaxError); ok {
+					encoder.Encode(jsonrpcErrorResponse(jsonrpcNullID, jsonrpcParseError, "Parse error", nil))
+				}
+			}
+			return
+		}
+
+		if resp, ok := s.handleJSONRPC(ctx, raw); ok {
+			if err := encoder.Encode(resp); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *Server) JSONRPCHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "只支持POST", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var resp any
+		var ok bool
+		var raw json.RawMessage
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, httpMaxBodySize)).Decode(&raw); tooLarge(err) {
+			http.Error(w, "请求太大", http.StatusRequestEntityTooLarge)
+			return
+		} else if err != nil {
+			resp, ok = jsonrpcErrorResponse(jsonrpcNullID, jsonrpcParseError, "Parse error", nil), true
+		} else {
+			ctx := context.WithValue(withPeer(r.Context(), newHTTPPeer(r)), httpRequestKey{}, r)
+			resp, ok = s.handleJSONRPC(ctx, raw)
+		}
+
+		if !ok {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	})
+}
+
+// 通知不需要响应，返回false
+func (s *Server) handleJSONRPC(ctx context.Context, raw json.RawMessage) (any, bool) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || raw[0] != '[' {
+		resp := s.handleJSONRPCRequest(ctx, raw)
+		return resp, resp != nil
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(raw, &batch); err != nil || len(batch) == 0 {
+		return jsonrpcErrorResponse(jsonrpcNullID, jsonrpcInvalidRequest, "Invalid Request", nil), true
+	}
+
+	var resps []*jsonrpcResponse
+	for _, r := range batch {
+		if resp := s.handleJSONRPCRequest(ctx, r); resp != nil {
+			resps = append(resps, resp)
+		}
+	}
+	return resps, len(resps) > 0
+}
+
+func (s *Server) handleJSONRPCRequest(ctx context.Context, raw json.RawMessage) *jsonrpcResponse {
+	var req jsonrpcRequest
+	if len(raw) == 0 || raw[0] != '{' || json.Unmarshal(raw, &req) != nil || req.JSONRPC != "2.0" || req.Method == "" || !validJSONRPCID(req.ID) {
+		id := req.ID
+		if !validJSONRPCID(id) || id == nil {
+			id = jsonrpcNullID
+		}
+		return jsonrpcErrorResponse(id, jsonrpcInvalidRequest, "Invalid Request", nil)
+	}
+
+	p := param{InArgs: []any{}}
+	// JSON-RPC没有metadata，HTTP请求的话从请求头里取
+	if r, ok := ctx.Value(httpRequestKey{}).(*http.Request); ok {
+		p.Metadata = withHeaderMetadata(nil, r)
+	}
+	if i := strings.LastIndex(req.Method, "."); i > 0 {
+		p.ServiceName, p.MethodName = req.Method[:i], req.Method[i+1:]
+	}
+
+	var resp *jsonrpcResponse
+	_, _, lookupErr := s.lookup(p.ServiceName, p.MethodName)
+	params := bytes.TrimSpace(req.Params)
+	switch {
+	case lookupErr != nil:
+		resp = jsonrpcErrorResponse(req.ID, jsonrpcMethodNotFound, "Method not found", lookupErr.Message)
+	case len(params) > 0 && params[0] == '{':
+		resp = jsonrpcErrorResponse(req.ID, jsonrpcInvalidParams, "Invalid params", "不支持按名字传参")
+	case len(params) > 0 && params[0] != '[':
+		resp = jsonrpcErrorResponse(req.ID, jsonrpcInvalidRequest, "Invalid Request", nil)
+	case len(params) > 0 && json.Unmarshal(params, &p.InArgs) != nil:
+		resp = jsonrpcErrorResponse(req.ID, jsonrpcInvalidParams, "Invalid params", nil)
+	default:
+		s.call(ctx, &p)
+		resp = jsonrpcResult(req.ID, &p)
+	}
+
+	if req.ID == nil {
+		return nil
+	}
+	return resp
+}
+
+func jsonrpcResult(id json.RawMessage, p *param) *jsonrpcResponse {
+	if p.Error != "" {
+		switch p.Code {
+		case CodeNotFound:
+			return jsonrpcErrorResponse(id, jsonrpcMethodNotFound, "Method not found", p.Error)
+		case CodeInvalidArgument:
+			return jsonrpcErrorResponse(id, jsonrpcInvalidParams, "Invalid params", p.Error)
+		case CodeInternal:
+			return jsonrpcErrorResponse(id, jsonrpcInternalError, "Internal error", p.Error)
+		}
+		return jsonrpcErrorResponse(id, jsonrpcServerError-int(p.Code), p.Error, p.Code.String())
+	}
+
+	var result any
+	switch len(p.OutArgs) {
+	case 0:
+	case 1:
+		result = p.OutArgs[0]
+	default:
+		result = p.OutArgs
+	}
+	b, err := json.Marshal(result)
+	if err != nil {
+		return jsonrpcErrorResponse(id, jsonrpcInternalError, "Internal error", err.Error())
+	}
+	return &jsonrpcResponse{JSONRPC: "2.0", Result: b, ID: id}
+}
+
+func jsonrpcErrorResponse(id json.RawMessage, code int, message string, data any) *jsonrpcResponse {
+	return &jsonrpcResponse{
+		JSONRPC: "2.0",
+		Error:   &jsonrpcError{Code: code, Message: message, Data: data},
+		ID:      id,
+	}
+}
+
+// id只能是字符串、数字或者null
+func validJSONRPCID(id json.RawMessage) bool {
+	if id == nil {
+		return true
+	}
+	var v any
+	if err := json.Unmarshal(id, &v); err != nil {
+		return false
+	}
+	switch v.(type) {
+	case nil, string, float64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,222 @@
+package rpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type Calc struct{}
+
+func (c *Calc) Subtract(a int, b int) int {
+	return a - b
+}
+
+func (c *Calc) Sum(a int, b int, d int) int {
+	return a + b + d
+}
+
+func (c *Calc) NotifyHello(n int) {
+}
+
+func (c *Calc) Update(a, b, d, e, f int) {
+}
+
+func (c *Calc) GetData() (string, int) {
+	return "hello", 5
+}
+
+// 只比较result、error.code和id，error.message和data可以不一样
+func normalizeJSONRPC(t *testing.T, s string) any {
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatal(s, err)
+	}
+	var normalize func(v any) any
+	normalize = func(v any) any {
+		switch v := v.(type) {
+		case []any:
+			for i := range v {
+				v[i] = normalize(v[i])
+			}
+		case map[string]any:
+			if e, ok := v["error"].(map[string]any); ok {
+				v["error"] = e["code"]
+			}
+		}
+		return v
+	}
+	return normalize(v)
+}
+
+// 用例来自 https://www.jsonrpc.org/specification 的Examples
+func TestJSONRPCSpec(t *testing.T) {
+	server := NewServer()
+	server.Register(new(Calc), "Calc")
+	ts := httptest.NewServer(server.JSONRPCHandler())
+	defer ts.Close()
+
+	for _, tc := range []struct {
+		name string
+		req  string
+		resp string
+	}{
+		{"positional params", `{"jsonrpc": "2.0", "method": "Calc.Subtract", "params": [42, 23], "id": 1}`, `{"jsonrpc": "2.0", "result": 19, "id": 1}`},
+		{"positional params 2", `{"jsonrpc": "2.0", "method": "Calc.Subtract", "params": [23, 42], "id": 2}`, `{"jsonrpc": "2.0", "result": -19, "id": 2}`},
+		{"named params", `{"jsonrpc": "2.0", "method": "Calc.Subtract", "params": {"subtrahend": 23, "minuend": 42}, "id": 3}`, `{"jsonrpc": "2.0", "error": {"code": -32602}, "id": 3}`},
+		{"notification", `{"jsonrpc": "2.0", "method": "Calc.Update", "params": [1, 2, 3, 4, 5]}`, ``},
+		{"notification 2", `{"jsonrpc": "2.0", "method": "Calc.Foobar"}`, ``},
+		{"non-existent method", `{"jsonrpc": "2.0", "method": "foobar", "id": "1"}`, `{"jsonrpc": "2.0", "error": {"code": -32601}, "id": "1"}`},
+		{"invalid JSON", `{"jsonrpc": "2.0", "method": "foobar, "params": "bar", "baz]`, `{"jsonrpc": "2.0", "error": {"code": -32700}, "id": null}`},
+		{"invalid Request object", `{"jsonrpc": "2.0", "method": 1, "params": "bar"}`, `{"jsonrpc": "2.0", "error": {"code": -32600}, "id": null}`},
+		{"batch invalid JSON", `[
+  {"jsonrpc": "2.0", "method": "Calc.Sum", "params": [1,2,4], "id": "1"},
+  {"jsonrpc": "2.0", "method"
+]`, `{"jsonrpc": "2.0", "error": {"code": -32700}, "id": null}`},
+		{"empty Array", `[]`, `{"jsonrpc": "2.0", "error": {"code": -32600}, "id": null}`},
+		{"invalid batch", `[1]`, `[{"jsonrpc": "2.0", "error": {"code": -32600}, "id": null}]`},
+		{"invalid batch 2", `[1,2,3]`, `[
+  {"jsonrpc": "2.0", "error": {"code": -32600}, "id": null},
+  {"jsonrpc": "2.0", "error": {"code": -32600}, "id": null},
+  {"jsonrpc": "2.0", "error": {"code": -32600}, "id": null}
+]`},
+		{"batch", `[
+  {"jsonrpc": "2.0", "method": "Calc.Sum", "params": [1,2,4], "id": "1"},
+  {"jsonrpc": "2.0", "method": "Calc.NotifyHello", "params": [7]},
+  {"jsonrpc": "2.0", "method": "Calc.Subtract", "params": [42,23], "id": "2"},
+  {"foo": "boo"},
+  {"jsonrpc": "2.0", "method": "foo.get", "params": {"name": "myself"}, "id": "5"},
+  {"jsonrpc": "2.0", "method": "Calc.GetData", "id": "9"}
+]`, `[
+  {"jsonrpc": "2.0", "result": 7, "id": "1"},
+  {"jsonrpc": "2.0", "result": 19, "id": "2"},
+  {"jsonrpc": "2.0", "error": {"code": -32600}, "id": null},
+  {"jsonrpc": "2.0", "error": {"code": -32601}, "id": "5"},
+  {"jsonrpc": "2.0", "result": ["hello", 5], "id": "9"}
+]`},
+		{"batch all notifications", `[
+  {"jsonrpc": "2.0", "method": "Calc.NotifyHello", "params": [1]},
+  {"jsonrpc": "2.0", "method": "Calc.Update", "params": [1, 2, 3, 4, 5]}
+]`, ``},
+		{"wrong version", `{"jsonrpc": "1.0", "method": "Calc.Subtract", "params": [42, 23], "id": 1}`, `{"jsonrpc": "2.0", "error": {"code": -32600}, "id": 1}`},
+		{"invalid params", `{"jsonrpc": "2.0", "method": "Calc.Subtract", "params": [42], "id": 1}`, `{"jsonrpc": "2.0", "error": {"code": -32602}, "id": 1}`},
+		{"null result", `{"jsonrpc": "2.0", "method": "Calc.NotifyHello", "params": [1], "id": 1}`, `{"jsonrpc": "2.0", "result": null, "id": 1}`},
+	} {
+		res, err := http.Post(ts.URL, "application/json", strings.NewReader(tc.req))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body strings.Builder
+		bufio.NewReader(res.Body).WriteTo(&body)
+		res.Body.Close()
+
+		if tc.resp == "" {
+			if res.StatusCode != http.StatusNoContent || body.Len() != 0 {
+				t.Errorf("%s: %d %s", tc.name, res.StatusCode, body.String())
+			}
+			continue
+		}
+		if got, want := normalizeJSONRPC(t, body.String()), normalizeJSONRPC(t, tc.resp); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %s", tc.name, body.String())
+		}
+	}
+}
+
+func TestJSONRPCResultOmitsError(t *testing.T) {
+	server := NewServer()
+	server.Register(new(Calc), "Calc")
+
+	resp, _ := server.handleJSONRPC(context.Background(), json.RawMessage(`{"jsonrpc": "2.0", "method": "Calc.NotifyHello", "params": [1], "id": 1}`))
+	b, _ := json.Marshal(resp)
+	if string(b) != `{"jsonrpc":"2.0","result":null,"id":1}` {
+		t.Error(string(b))
+	}
+
+	resp, _ = server.handleJSONRPC(context.Background(), json.RawMessage(`{"jsonrpc": "2.0", "method": "Calc.Nope", "id": null}`))
+	b, _ = json.Marshal(resp)
+	if strings.Contains(string(b), "result") || !strings.Contains(string(b), `"id":null`) {
+		t.Error(string(b))
+	}
+}
+
+func TestServeJSONRPC(t *testing.T) {
+	server := NewServer()
+	server.Register(new(Calc), "Calc")
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go server.ServeJSONRPC(conn)
+		}
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Write([]byte(`{"jsonrpc": "2.0", "method": "Calc.Subtract", "params": [42, 23], "id": 1}
+{"jsonrpc": "2.0", "method": "Calc.NotifyHello", "params": [7]}
+[{"jsonrpc": "2.0", "method": "Calc.GetData", "id": 2}]
+`))
+
+	r := bufio.NewReader(conn)
+	line, _ := r.ReadString('\n')
+	if got := normalizeJSONRPC(t, line); !reflect.DeepEqual(got, normalizeJSONRPC(t, `{"jsonrpc": "2.0", "result": 19, "id": 1}`)) {
+		t.Error(line)
+	}
+	line, _ = r.ReadString('\n')
+	if got := normalizeJSONRPC(t, line); !reflect.DeepEqual(got, normalizeJSONRPC(t, `[{"jsonrpc": "2.0", "result": ["hello", 5], "id": 2}]`)) {
+		t.Error(line)
+	}
+
+	conn.Write([]byte(`{"jsonrpc": "2.0", "method": "foobar, "params": "bar", "baz]`))
+	line, _ = r.ReadString('\n')
+	if got := normalizeJSONRPC(t, line); !reflect.DeepEqual(got, normalizeJSONRPC(t, `{"jsonrpc": "2.0", "error": {"code": -32700}, "id": null}`)) {
+		t.Error(line)
+	}
+
+	conn.Close()
+	l.Close()
+}
+
+// 参数的形状不对时返回Invalid params，不能让服务端panic
+func TestJSONRPCInvalidParamShape(t *testing.T) {
+	server := NewServer()
+	server.Register(new(Userservice), "UserService")
+	serverConn, conn := net.Pipe()
+	go server.ServeJSONRPC(serverConn)
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	for i, params := range []string{
+		`"UserService.GrowUpStruct", "params": [1]`,
+		`"UserService.GrowUpPointer", "params": ["x"]`,
+		`"UserService.GrowUpStruct", "params": [{"Address": 1}]`,
+		`"UserService.GrowUpStruct", "params": [{"HobbiesArr": ["a", "b", "c", "d"]}]`,
+		`"UserService.TestTime", "params": [1]`,
+		`"UserService.TestTimePtr", "params": [1]`,
+		`"UserService.Sum", "params": [{"a": 1}]`,
+		`"UserService.SumUserAgeStruct", "params": [[1]]`,
+		`"UserService.Add", "params": [null, 1]`,
+	} {
+		fmt.Fprintf(conn, `{"jsonrpc": "2.0", "method": %s, "id": %d}`+"\n", params, i)
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(params, err)
+		}
+		want := fmt.Sprintf(`{"jsonrpc": "2.0", "error": {"code": -32602}, "id": %d}`, i)
+		if got := normalizeJSONRPC(t, line); !reflect.DeepEqual(got, normalizeJSONRPC(t, want)) {
+			t.Error(params, line)
+		}
+	}
+}
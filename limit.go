@@ -0,0 +1,186 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 超过限制时trailer里带上多久之后可以重试，单位是毫秒
+const retryAfterKey = "retry-after-ms"
+
+// 不知道正在处理的调用什么时候结束，让客户端等一个固定的时间
+const inflightRetryAfter = 100 * time.Millisecond
+
+type rateLimit struct {
+	rate  float64
+	burst int
+}
+
+// rate是0的桶永远补不满，算不出要等多久
+func newRateLimit(rate float64, burst int) rateLimit {
+	if rate <= 0 || burst < 1 {
+		panic("rpc: 限流的rate要大于0，burst至少是1")
+	}
+	return rateLimit{rate, burst}
+}
+
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(l rateLimit) *tokenBucket {
+	return &tokenBucket{
+		rate:   l.rate,
+		burst:  float64(l.burst),
+		tokens: float64(l.burst),
+		last:   time.Now(),
+	}
+}
+
+// 拿不到令牌时返回还要等多久
+func (b *tokenBucket) take(now time.Time) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if now.After(b.last) {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// 后面的桶拒绝了调用时把令牌还回去
+func (b *tokenBucket) refund() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens++
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+func (b *tokenBucket) idle(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.tokens+now.Sub(b.last).Seconds()*b.rate >= b.burst
+}
+
+type limiter struct {
+	conn     *rateLimit
+	peer     *rateLimit
+	methods  map[string]*tokenBucket
+	inflight chan struct{}
+
+	mu    sync.Mutex
+	peers map[string]*tokenBucket
+}
+
+func newLimiter(o serverOptions) *limiter {
+	if o.connLimit == nil && o.peerLimit == nil && len(o.methodLimits) == 0 && o.maxConcurrent <= 0 {
+		return nil
+	}
+	l := &limiter{
+		conn:    o.connLimit,
+		peer:    o.peerLimit,
+		methods: make(map[string]*tokenBucket),
+		peers:   make(map[string]*tokenBucket),
+	}
+	for method, limit := range o.methodLimits {
+		l.methods[method] = newTokenBucket(limit)
+	}
+	if o.maxConcurrent > 0 {
+		l.inflight = make(chan struct{}, o.maxConcurrent)
+	}
+	return l
+}
+
+type connBucketKey struct{}
+
+// 每个连接一个令牌桶
+func (s *Server) connContext(ctx context.Context) context.Context {
+	if s.limiter == nil || s.limiter.conn == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, connBucketKey{}, newTokenBucket(*s.limiter.conn))
+}
+
+// 同一个IP的连接共用一个令牌桶，长时间不用的桶在桶的数量变多时清掉
+func (l *limiter) peerBucket(ip string, now time.Time) *tokenBucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.peers[ip]
+	if !ok {
+		if len(l.peers) >= 1024 {
+			for k, v := range l.peers {
+				if v.idle(now) {
+					delete(l.peers, k)
+				}
+			}
+		}
+		b = newTokenBucket(*l.peer)
+		l.peers[ip] = b
+	}
+	return b
+}
+
+func peerIP(ctx context.Context) string {
+	p, ok := PeerFromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+func (s *Server) limit(next Handler) Handler {
+	l := s.limiter
+	return func(ctx context.Context, call *CallInfo) ([]any, error) {
+		now := time.Now()
+		var buckets []*tokenBucket
+		if b, ok := ctx.Value(connBucketKey{}).(*tokenBucket); ok {
+			buckets = append(buckets, b)
+		}
+		if l.peer != nil {
+			buckets = append(buckets, l.peerBucket(peerIP(ctx), now))
+		}
+		if b, ok := l.methods[call.ServiceName+"."+call.MethodName]; ok {
+			buckets = append(buckets, b)
+		}
+		for i, b := range buckets {
+			if ok, wait := b.take(now); !ok {
+				for _, taken := range buckets[:i] {
+					taken.refund()
+				}
+				SetTrailer(ctx, retryAfterKey, strconv.FormatInt(wait.Milliseconds()+1, 10))
+				return nil, &Error{CodeResourceExhausted, "请求太频繁"}
+			}
+		}
+
+		if l.inflight != nil {
+			select {
+			case l.inflight <- struct{}{}:
+				defer func() { <-l.inflight }()
+			default:
+				SetTrailer(ctx, retryAfterKey, strconv.FormatInt(inflightRetryAfter.Milliseconds(), 10))
+				return nil, &Error{CodeResourceExhausted, "正在处理的请求太多"}
+			}
+		}
+		return next(ctx, call)
+	}
+}
@@ -0,0 +1,179 @@
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func callAdd(client *Client) (Metadata, error) {
+	var trailer Metadata
+	_, err := client.CallContext(WithTrailer(context.Background(), &trailer), "UserService", "Add", []interface{}{1, 2})
+	return trailer, err
+}
+
+func TestConnRateLimit(t *testing.T) {
+	server := NewServer(WithConnRateLimit(1, 2))
+	server.Register(new(Userservice), "UserService")
+	client := pipeClient(server)
+	defer client.Close()
+
+	for i := 0; i < 2; i++ {
+		if _, err := callAdd(client); err != nil {
+			t.Fatal(err)
+		}
+	}
+	trailer, err := callAdd(client)
+	if ErrorCode(err) != CodeResourceExhausted {
+		t.Fatal(err)
+	}
+	if ms, _ := strconv.Atoi(trailer.Get("retry-after-ms")); ms <= 0 || ms > 1000 {
+		t.Error(trailer)
+	}
+
+	// 新连接有自己的令牌桶
+	other := pipeClient(server)
+	defer other.Close()
+	if _, err := callAdd(other); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPeerRateLimit(t *testing.T) {
+	server := NewServer(WithPeerRateLimit(1, 2))
+	server.Register(new(Userservice), "UserService")
+
+	// net.Pipe的地址都一样，当作同一个IP
+	a := pipeClient(server)
+	defer a.Close()
+	b := pipeClient(server)
+	defer b.Close()
+	if _, err := callAdd(a); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := callAdd(b); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := callAdd(a); ErrorCode(err) != CodeResourceExhausted {
+		t.Error(err)
+	}
+	if _, err := callAdd(b); ErrorCode(err) != CodeResourceExhausted {
+		t.Error(err)
+	}
+}
+
+func TestMethodRateLimit(t *testing.T) {
+	server := NewServer(WithMethodRateLimit("UserService.Add", 1000, 1))
+	server.Register(new(Userservice), "UserService")
+	client := pipeClient(server)
+	defer client.Close()
+
+	if _, err := callAdd(client); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := callAdd(client); ErrorCode(err) != CodeResourceExhausted {
+		t.Error(err)
+	}
+	if _, err := client.Call("UserService", "GetUserById", []interface{}{1}); err != nil {
+		t.Error(err)
+	}
+	// 令牌很快就补回来了
+	time.Sleep(5 * time.Millisecond)
+	if _, err := callAdd(client); err != nil {
+		t.Error(err)
+	}
+}
+
+// 方法的限制拒绝的调用不能用掉连接的令牌
+func TestRateLimitRefund(t *testing.T) {
+	server := NewServer(WithConnRateLimit(0.001, 3), WithMethodRateLimit("UserService.Add", 0.001, 1))
+	server.Register(new(Userservice), "UserService")
+	client := pipeClient(server)
+	defer client.Close()
+
+	if _, err := callAdd(client); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := callAdd(client); ErrorCode(err) != CodeResourceExhausted {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := client.Call("UserService", "GetUserById", []interface{}{1}); err != nil {
+			t.Error(i, err)
+		}
+	}
+}
+
+func TestRateLimitInvalid(t *testing.T) {
+	for _, option := range []func() ServerOption{
+		func() ServerOption { return WithConnRateLimit(0, 1) },
+		func() ServerOption { return WithPeerRateLimit(-1, 1) },
+		func() ServerOption { return WithMethodRateLimit("UserService.Add", 1, 0) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("应该panic")
+				}
+			}()
+			option()
+		}()
+	}
+}
+
+func TestMaxConcurrent(t *testing.T) {
+	server := NewServer(WithMaxConcurrent(1))
+	server.Register(new(Userservice), "UserService")
+	slow := pipeClient(server)
+	defer slow.Close()
+	client := pipeClient(server)
+	defer client.Close()
+
+	done := make(chan error)
+	go func() {
+		_, err := slow.Call("UserService", "Sleep", []interface{}{100})
+		done <- err
+	}()
+	time.Sleep(30 * time.Millisecond)
+
+	trailer, err := callAdd(client)
+	if ErrorCode(err) != CodeResourceExhausted {
+		t.Error(err)
+	}
+	if trailer.Get("retry-after-ms") != "100" {
+		t.Error(trailer)
+	}
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+	if _, err := callAdd(client); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRateLimitHTTP(t *testing.T) {
+	server := NewServer(WithPeerRateLimit(0.5, 1))
+	server.Register(new(Userservice), "UserService")
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		res, err := http.Post(ts.URL+"/rpc/UserService/Add", "application/json", strings.NewReader("[1, 2]"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != want {
+			t.Error(i, res.Status)
+		}
+		if want == http.StatusTooManyRequests && res.Header.Get("Retry-After") != "2" {
+			t.Error(res.Header.Get("Retry-After"))
+		}
+	}
+}
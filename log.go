@@ -0,0 +1,124 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+)
+
+// 参数记到日志之前先经过这个函数，可以把密码之类的字段去掉
+type Redactor func(service, method string, args []any) []any
+
+const redacted = "[REDACTED]"
+
+// 把参数里名字是fields之一的字段换成[REDACTED]，不区分大小写，嵌套的结构体和map也会处理
+func RedactFields(fields ...string) Redactor {
+	names := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		names[strings.ToLower(f)] = true
+	}
+	return func(service, method string, args []any) []any {
+		// 转成通用的map和slice再处理，不改原来的参数
+		b, err := json.Marshal(args)
+		if err != nil {
+			return []any{redacted}
+		}
+		var out []any
+		if err := json.Unmarshal(b, &out); err != nil {
+			return []any{redacted}
+		}
+		for i := range out {
+			out[i] = redact(out[i], names)
+		}
+		return out
+	}
+}
+
+func redact(v any, names map[string]bool) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			if names[strings.ToLower(k)] {
+				v[k] = redacted
+			} else {
+				v[k] = redact(e, names)
+			}
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = redact(e, names)
+		}
+	}
+	return v
+}
+
+func peerAddr(ctx context.Context) string {
+	if p, ok := PeerFromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return ""
+}
+
+// 每次调用一条访问日志，成功是Info，Internal和Unknown是Error，其他错误是Warn
+func (s *Server) logCall(ctx context.Context, p *param, inArgs []any, duration time.Duration) {
+	level := slog.LevelInfo
+	switch p.Code {
+	case CodeOK:
+	case CodeInternal, CodeUnknown:
+		level = slog.LevelError
+	default:
+		level = slog.LevelWarn
+	}
+	logger := s.opts.logger
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("peer", peerAddr(ctx)),
+		slog.String("service", p.ServiceName),
+		slog.String("method", p.MethodName),
+		slog.Duration("duration", duration),
+		slog.String("code", p.Code.String()),
+		slog.Int("request_size", payloadSize(s.opts.codec, inArgs)),
+	}
+	if p.Error != "" {
+		attrs = append(attrs, slog.String("error", p.Error))
+	} else {
+		attrs = append(attrs, slog.Int("response_size", payloadSize(s.opts.codec, p.OutArgs)))
+	}
+	if s.opts.logArgs {
+		args := inArgs
+		if s.opts.redact != nil {
+			args = s.opts.redact(p.ServiceName, p.MethodName, inArgs)
+		}
+		attrs = append(attrs, slog.Any("args", args))
+	}
+	if sc := SpanContextFromContext(ctx); sc.IsValid() {
+		attrs = append(attrs, slog.String("trace_id", sc.TraceID.String()))
+	}
+	logger.LogAttrs(ctx, level, "rpc call", attrs...)
+}
+
+func (s *Server) logConn(ctx context.Context, msg string) {
+	if s.opts.logger != nil {
+		s.opts.logger.LogAttrs(ctx, slog.LevelInfo, msg, slog.String("peer", peerAddr(ctx)))
+	}
+}
+
+// 连接上的数据解不出来，连接会被关闭
+func (s *Server) logDecodeError(ctx context.Context, err error) {
+	if s.opts.logger != nil {
+		s.opts.logger.LogAttrs(ctx, slog.LevelWarn, "rpc decode error", slog.String("peer", peerAddr(ctx)), slog.String("error", err.Error()))
+	}
+}
+
+// 对端正常关闭连接不算解码错误
+func closedConnError(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) || errors.Is(err, io.ErrClosedPipe)
+}
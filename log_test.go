@@ -0,0 +1,168 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 服务端在别的goroutine里写日志
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) records(t *testing.T) []map[string]any {
+	t.Helper()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var r map[string]any
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatal(line)
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+func (b *logBuffer) find(t *testing.T, msg string) []map[string]any {
+	var found []map[string]any
+	for _, r := range b.records(t) {
+		if r["msg"] == msg {
+			found = append(found, r)
+		}
+	}
+	return found
+}
+
+func newLogServer(logs *logBuffer, opts ...ServerOption) *Server {
+	logger := slog.New(slog.NewJSONHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	server := NewServer(append([]ServerOption{WithLogger(logger)}, opts...)...)
+	server.Register(new(Userservice), "UserService")
+	return server
+}
+
+func TestAccessLog(t *testing.T) {
+	var logs logBuffer
+	client := pipeClient(newLogServer(&logs))
+
+	if _, err := client.Call("UserService", "Add", []interface{}{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	client.Call("UserService", "Missing", nil)
+
+	records := logs.find(t, "rpc call")
+	if len(records) != 2 {
+		t.Fatal(records)
+	}
+	ok, missing := records[0], records[1]
+	if ok["level"] != "INFO" || ok["service"] != "UserService" || ok["method"] != "Add" || ok["code"] != "OK" ||
+		ok["request_size"] != 6.0 || ok["response_size"] != 4.0 || ok["peer"] != "pipe" {
+		t.Error(ok)
+	}
+	if _, found := ok["duration"]; !found {
+		t.Error(ok)
+	}
+	if _, found := ok["args"]; found {
+		t.Error("默认不记参数", ok)
+	}
+	if missing["level"] != "WARN" || missing["code"] != "NotFound" || missing["error"] != "服务没找到" && missing["error"] != "方法没找到" {
+		t.Error(missing)
+	}
+
+	client.Close()
+	deadline := time.Now().Add(time.Second)
+	for len(logs.find(t, "rpc connection closed")) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("没有记录连接关闭")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if opened := logs.find(t, "rpc connection opened"); len(opened) != 1 {
+		t.Error(opened)
+	}
+	// 正常关闭不算解码错误
+	if errs := logs.find(t, "rpc decode error"); len(errs) != 0 {
+		t.Error(errs)
+	}
+}
+
+func TestAccessLogRedact(t *testing.T) {
+	var logs logBuffer
+	client := pipeClient(newLogServer(&logs, WithLogArgs(RedactFields("name", "HomeAddr"))))
+	defer client.Close()
+
+	u := user{ID: 1, Name: "Guobin", Address: address{HomeAddr: "Shanghai", OfficeAddr: "Beijing"}}
+	if _, err := client.Call("UserService", "GrowUpStruct", []interface{}{u}); err != nil {
+		t.Fatal(err)
+	}
+
+	records := logs.find(t, "rpc call")
+	if len(records) != 1 {
+		t.Fatal(records)
+	}
+	b, _ := json.Marshal(records[0]["args"])
+	s := string(b)
+	if strings.Contains(s, "Guobin") || strings.Contains(s, "Shanghai") || !strings.Contains(s, "Beijing") || !strings.Contains(s, redacted) {
+		t.Error(s)
+	}
+}
+
+func TestRedactFields(t *testing.T) {
+	args := []any{map[string]any{"Password": "123", "Items": []any{map[string]any{"password": "456"}}}, "password"}
+	got := RedactFields("password")("Svc", "Login", args)
+	b, _ := json.Marshal(got)
+	if string(b) != `[{"Items":[{"password":"[REDACTED]"}],"Password":"[REDACTED]"},"password"]` {
+		t.Error(string(b))
+	}
+	if args[0].(map[string]any)["Password"] != "123" {
+		t.Error("不能改原来的参数", args)
+	}
+}
+
+func TestDecodeErrorLog(t *testing.T) {
+	var logs logBuffer
+	server := newLogServer(&logs)
+	serverConn, clientConn := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		server.ServeConn(serverConn)
+		close(done)
+	}()
+	clientConn.Write([]byte("{not json\n"))
+	clientConn.Close()
+	<-done
+
+	if errs := logs.find(t, "rpc decode error"); len(errs) != 1 || errs[0]["peer"] != "pipe" {
+		t.Error(errs)
+	}
+
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	res, err := http.Post(ts.URL+"/rpc", "application/json", strings.NewReader("{"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if errs := logs.find(t, "rpc decode error"); len(errs) != 2 || !strings.HasPrefix(errs[1]["peer"].(string), "127.0.0.1:") {
+		t.Error(errs)
+	}
+}
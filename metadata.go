@@ -0,0 +1,136 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// 和gRPC的metadata一样，key不区分大小写，统一存成小写
+type Metadata map[string][]string
+
+// kv是key, value, key, value...
+func NewMetadata(kv ...string) Metadata {
+	md := Metadata{}
+	md.append(kv)
+	return md
+}
+
+func (md Metadata) Get(key string) string {
+	if v := md[strings.ToLower(key)]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (md Metadata) append(kv []string) {
+	if len(kv)%2 == 1 {
+		panic("rpc: metadata的key和value个数不匹配")
+	}
+	for i := 0; i < len(kv); i += 2 {
+		key := strings.ToLower(kv[i])
+		md[key] = append(md[key], kv[i+1])
+	}
+}
+
+// 网络上解码出来的key可能有大写，收到的时候统一转成小写，Get才能找到
+func (md Metadata) lower() Metadata {
+	lowered := true
+	for k := range md {
+		if k != strings.ToLower(k) {
+			lowered = false
+			break
+		}
+	}
+	if lowered {
+		return md
+	}
+	c := make(Metadata, len(md))
+	for k, v := range md {
+		key := strings.ToLower(k)
+		c[key] = append(c[key], v...)
+	}
+	return c
+}
+
+func (md Metadata) copy() Metadata {
+	c := make(Metadata, len(md))
+	for k, v := range md {
+		c[k] = append([]string(nil), v...)
+	}
+	return c
+}
+
+type outgoingKey struct{}
+type incomingKey struct{}
+type trailerKey struct{}
+type trailerReceiverKey struct{}
+
+// 客户端在ctx上附加要发给服务端的metadata，可以多次调用
+func WithMetadata(ctx context.Context, kv ...string) context.Context {
+	md, _ := ctx.Value(outgoingKey{}).(Metadata)
+	md = md.copy()
+	md.append(kv)
+	return context.WithValue(ctx, outgoingKey{}, md)
+}
+
+func outgoingMetadata(ctx context.Context) Metadata {
+	md, _ := ctx.Value(outgoingKey{}).(Metadata)
+	return md
+}
+
+// 服务端的方法和拦截器用来读客户端发来的metadata
+func MetadataFromContext(ctx context.Context) Metadata {
+	md, _ := ctx.Value(incomingKey{}).(Metadata)
+	if md == nil {
+		return Metadata{}
+	}
+	return md
+}
+
+func withIncomingMetadata(ctx context.Context, md Metadata) context.Context {
+	return context.WithValue(ctx, incomingKey{}, md)
+}
+
+type trailer struct {
+	mu sync.Mutex
+	md Metadata
+}
+
+func withTrailer(ctx context.Context) (context.Context, *trailer) {
+	t := &trailer{}
+	return context.WithValue(ctx, trailerKey{}, t), t
+}
+
+func (t *trailer) get() Metadata {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.md
+}
+
+// 服务端在响应里带回给客户端的metadata，出错的时候也会带回
+func SetTrailer(ctx context.Context, kv ...string) error {
+	t, ok := ctx.Value(trailerKey{}).(*trailer)
+	if !ok {
+		return errors.New("ctx不是服务端调用的ctx")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.md == nil {
+		t.md = Metadata{}
+	}
+	t.md.append(kv)
+	return nil
+}
+
+// 客户端用来接收服务端的trailer，调用返回后md里就是trailer，出错时也一样
+func WithTrailer(ctx context.Context, md *Metadata) context.Context {
+	return context.WithValue(ctx, trailerReceiverKey{}, md)
+}
+
+func receiveTrailer(ctx context.Context, md Metadata) {
+	if p, ok := ctx.Value(trailerReceiverKey{}).(*Metadata); ok {
+		*p = md.lower()
+	}
+}
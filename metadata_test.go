@@ -0,0 +1,137 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+)
+
+type Meta struct{}
+
+func (m *Meta) Echo(ctx context.Context, key string) string {
+	SetTrailer(ctx, "served-by", "meta")
+	return MetadataFromContext(ctx).Get(key)
+}
+
+func (m *Meta) Values(ctx context.Context, key string) []string {
+	return MetadataFromContext(ctx)[key]
+}
+
+func TestMetadata(t *testing.T) {
+	server := NewServer()
+	server.Register(new(Meta), "Meta")
+	client := pipeClient(server)
+	defer client.Close()
+
+	ctx := WithMetadata(context.Background(), "Authorization", "Bearer abc", "x-tag", "a")
+	ctx = WithMetadata(ctx, "X-Tag", "b")
+
+	var trailer Metadata
+	v, err := Call1[string](WithTrailer(ctx, &trailer), client, "Meta", "Echo", "authorization")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "Bearer abc" {
+		t.Error(v)
+	}
+	if trailer.Get("Served-By") != "meta" {
+		t.Error(trailer)
+	}
+
+	values, err := Call1[[]string](ctx, client, "Meta", "Values", "x-tag")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 2 || values[0] != "a" || values[1] != "b" {
+		t.Error(values)
+	}
+
+	// 没有metadata
+	v, err = Call1[string](context.Background(), client, "Meta", "Echo", "authorization")
+	if err != nil || v != "" {
+		t.Error(v, err)
+	}
+}
+
+func TestMetadataInterceptors(t *testing.T) {
+	server := NewServer()
+	server.Register(new(Meta), "Meta")
+	server.Use(func(next Handler) Handler {
+		return func(ctx context.Context, call *CallInfo) ([]any, error) {
+			if call.Metadata.Get("token") != "secret" {
+				SetTrailer(ctx, "reason", "no token")
+				return nil, &Error{Code: CodeInvalidArgument, Message: "没有token"}
+			}
+			call.Metadata["user"] = []string{"guobin"}
+			return next(ctx, call)
+		}
+	})
+
+	serverConn, clientConn := net.Pipe()
+	go server.ServeConn(serverConn)
+	client := DialConn(clientConn, WithInterceptor(func(next Invoker) Invoker {
+		return func(ctx context.Context, call *CallInfo) (*Out, error) {
+			if call.MethodName == "Echo" {
+				call.Metadata["token"] = []string{"secret"}
+			}
+			return next(ctx, call)
+		}
+	}))
+	defer client.Close()
+
+	v, err := Call1[string](context.Background(), client, "Meta", "Echo", "user")
+	if err != nil || v != "guobin" {
+		t.Error(v, err)
+	}
+
+	// 出错的时候也能拿到trailer
+	var trailer Metadata
+	_, err = Call1[[]string](WithTrailer(context.Background(), &trailer), client, "Meta", "Values", "user")
+	if ErrorCode(err) != CodeInvalidArgument || trailer.Get("reason") != "no token" {
+		t.Error(err, trailer)
+	}
+}
+
+// 其他语言的客户端直接发json，key的大小写不一定
+func TestMetadataMixedCase(t *testing.T) {
+	server := NewServer()
+	server.Register(new(Meta), "Meta")
+	serverConn, clientConn := net.Pipe()
+	go server.ServeConn(serverConn)
+	defer clientConn.Close()
+
+	io.WriteString(clientConn, `{"ServiceName": "Meta", "MethodName": "Echo", "InArgs": ["authorization"], "Metadata": {"Authorization": ["Bearer abc"]}}`+"\n")
+	var p param
+	if err := json.NewDecoder(clientConn).Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Error != "" || len(p.OutArgs) != 1 || p.OutArgs[0] != "Bearer abc" {
+		t.Error(p)
+	}
+
+	// 客户端收到的trailer也一样
+	serverConn, clientConn = net.Pipe()
+	go func() {
+		dec := json.NewDecoder(serverConn)
+		var req param
+		dec.Decode(&req)
+		io.WriteString(serverConn, `{"OutArgs": [], "Metadata": {"Served-By": ["raw"]}}`+"\n")
+	}()
+	client := DialConn(clientConn)
+	defer client.Close()
+	var trailer Metadata
+	if _, err := client.CallContext(WithTrailer(context.Background(), &trailer), "Meta", "Echo", []any{"x"}); err != nil {
+		t.Fatal(err)
+	}
+	if trailer.Get("served-by") != "raw" {
+		t.Error(trailer)
+	}
+}
+
+func TestSetTrailerOutsideCall(t *testing.T) {
+	if err := SetTrailer(context.Background(), "a", "b"); err == nil {
+		t.Error("expected error")
+	}
+}
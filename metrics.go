@@ -0,0 +1,339 @@
+package rpc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SideClient = "client"
+	SideServer = "server"
+)
+
+// 服务端不存在的服务和方法用的标签
+const unknownLabel = "unknown"
+
+// 监控指标，side是SideClient或SideServer，可以自己实现接到别的监控系统上
+type Metrics interface {
+	CallStarted(side, service, method string)
+	CallFinished(side, service, method string, code Code, latency time.Duration)
+	BytesTransferred(side string, in, out int)
+	ConnOpened(side string)
+	ConnClosed(side string)
+}
+
+// 统计连接上读写的字节数，关闭的时候算一次连接断开
+type meteredConn struct {
+	net.Conn
+	metrics Metrics
+	side    string
+	once    sync.Once
+}
+
+func meterConn(conn net.Conn, m Metrics, side string) net.Conn {
+	if m == nil {
+		return conn
+	}
+	m.ConnOpened(side)
+	return &meteredConn{Conn: conn, metrics: m, side: side}
+}
+
+func (c *meteredConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.metrics.BytesTransferred(c.side, n, 0)
+	}
+	return n, err
+}
+
+func (c *meteredConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.metrics.BytesTransferred(c.side, 0, n)
+	}
+	return n, err
+}
+
+func (c *meteredConn) Close() error {
+	c.once.Do(func() {
+		c.metrics.ConnClosed(c.side)
+	})
+	return c.Conn.Close()
+}
+
+type meteredReader struct {
+	io.Reader
+	metrics Metrics
+	side    string
+}
+
+func (r *meteredReader) Read(b []byte) (int, error) {
+	n, err := r.Reader.Read(b)
+	if n > 0 {
+		r.metrics.BytesTransferred(r.side, n, 0)
+	}
+	return n, err
+}
+
+type meteredWriter struct {
+	io.Writer
+	metrics Metrics
+	side    string
+}
+
+func (w *meteredWriter) Write(b []byte) (int, error) {
+	n, err := w.Writer.Write(b)
+	if n > 0 {
+		w.metrics.BytesTransferred(w.side, 0, n)
+	}
+	return n, err
+}
+
+// 和Prometheus客户端的默认分桶一样，单位是秒
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type methodKey struct {
+	side, service, method string
+}
+
+type codeKey struct {
+	methodKey
+	code Code
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// 内置的Metrics实现，本身是http.Handler，输出Prometheus的文本格式
+type PrometheusMetrics struct {
+	buckets []float64
+
+	mu         sync.Mutex
+	requests   map[codeKey]uint64
+	latency    map[methodKey]*histogram
+	inFlight   map[methodKey]int64
+	bytesIn    map[string]int64
+	bytesOut   map[string]int64
+	conns      map[string]int64
+	connsTotal map[string]int64
+}
+
+// 不传buckets时用DefaultBuckets
+func NewPrometheusMetrics(buckets ...float64) *PrometheusMetrics {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &PrometheusMetrics{
+		buckets:    buckets,
+		requests:   make(map[codeKey]uint64),
+		latency:    make(map[methodKey]*histogram),
+		inFlight:   make(map[methodKey]int64),
+		bytesIn:    make(map[string]int64),
+		bytesOut:   make(map[string]int64),
+		conns:      make(map[string]int64),
+		connsTotal: make(map[string]int64),
+	}
+}
+
+func (m *PrometheusMetrics) CallStarted(side, service, method string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inFlight[methodKey{side, service, method}]++
+}
+
+func (m *PrometheusMetrics) CallFinished(side, service, method string, code Code, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := methodKey{side, service, method}
+	m.inFlight[key]--
+	m.requests[codeKey{key, code}]++
+
+	h, ok := m.latency[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(m.buckets))}
+		m.latency[key] = h
+	}
+	seconds := latency.Seconds()
+	for i, le := range m.buckets {
+		if seconds <= le {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+func (m *PrometheusMetrics) BytesTransferred(side string, in, out int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bytesIn[side] += int64(in)
+	m.bytesOut[side] += int64(out)
+}
+
+func (m *PrometheusMetrics) ConnOpened(side string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.conns[side]++
+	m.connsTotal[side]++
+}
+
+func (m *PrometheusMetrics) ConnClosed(side string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.conns[side]--
+}
+
+func (m *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// 按Prometheus的文本格式输出，同一个指标的样本按标签排序
+func (m *PrometheusMetrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	cw := &countingWriter{w: bw}
+	for _, side := range []string{SideClient, SideServer} {
+		m.writeRequests(cw, side)
+		m.writeLatency(cw, side)
+		m.writeInFlight(cw, side)
+		m.writeSide(cw, side, "received_bytes_total", "counter", "读到的字节数", m.bytesIn)
+		m.writeSide(cw, side, "sent_bytes_total", "counter", "写出的字节数", m.bytesOut)
+		m.writeSide(cw, side, "connections", "gauge", "当前打开的连接数", m.conns)
+		m.writeSide(cw, side, "connections_total", "counter", "打开过的连接数", m.connsTotal)
+	}
+	if err := bw.Flush(); err != nil && cw.err == nil {
+		cw.err = err
+	}
+	return cw.n, cw.err
+}
+
+func (m *PrometheusMetrics) writeRequests(w io.Writer, side string) {
+	var keys []codeKey
+	for k := range m.requests {
+		if k.side == side {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].methodKey != keys[j].methodKey {
+			return keys[i].methodKey.less(keys[j].methodKey)
+		}
+		return keys[i].code < keys[j].code
+	})
+	name := "rpc_" + side + "_requests_total"
+	writeHeader(w, name, "counter", "完成的调用数")
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s{%s,code=\"%s\"} %d\n", name, k.labels(), k.code, m.requests[k])
+	}
+}
+
+func (m *PrometheusMetrics) writeLatency(w io.Writer, side string) {
+	keys := sideKeys(m.latency, side)
+	if len(keys) == 0 {
+		return
+	}
+	name := "rpc_" + side + "_request_duration_seconds"
+	writeHeader(w, name, "histogram", "调用的耗时")
+	for _, k := range keys {
+		h := m.latency[k]
+		for i, le := range m.buckets {
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", name, k.labels(), formatFloat(le), h.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, k.labels(), h.count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", name, k.labels(), formatFloat(h.sum))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", name, k.labels(), h.count)
+	}
+}
+
+func (m *PrometheusMetrics) writeInFlight(w io.Writer, side string) {
+	keys := sideKeys(m.inFlight, side)
+	if len(keys) == 0 {
+		return
+	}
+	name := "rpc_" + side + "_in_flight_requests"
+	writeHeader(w, name, "gauge", "正在进行的调用数")
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s{%s} %d\n", name, k.labels(), m.inFlight[k])
+	}
+}
+
+func (m *PrometheusMetrics) writeSide(w io.Writer, side, suffix, typ, help string, values map[string]int64) {
+	value, ok := values[side]
+	if !ok {
+		return
+	}
+	name := "rpc_" + side + "_" + suffix
+	writeHeader(w, name, typ, help)
+	fmt.Fprintf(w, "%s %d\n", name, value)
+}
+
+func sideKeys[V any](m map[methodKey]V, side string) []methodKey {
+	var keys []methodKey
+	for k := range m {
+		if k.side == side {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].less(keys[j])
+	})
+	return keys
+}
+
+func (k methodKey) less(o methodKey) bool {
+	if k.service != o.service {
+		return k.service < o.service
+	}
+	return k.method < o.method
+}
+
+func (k methodKey) labels() string {
+	return "service=\"" + escapeLabel(k.service) + "\",method=\"" + escapeLabel(k.method) + "\""
+}
+
+func writeHeader(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.w.Write(b)
+	w.n += int64(n)
+	w.err = err
+	return n, err
+}
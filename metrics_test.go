@@ -0,0 +1,203 @@
+package rpc
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 抓取/metrics，返回样本名(带标签)到值的映射
+func scrape(t *testing.T, url string) map[string]float64 {
+	t.Helper()
+	res, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if ct := res.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Error(ct)
+	}
+
+	samples := make(map[string]float64)
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndex(line, " ")
+		v, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			t.Fatal(line)
+		}
+		samples[line[:i]] = v
+	}
+	return samples
+}
+
+func TestMetrics(t *testing.T) {
+	metrics := NewPrometheusMetrics()
+	ts := httptest.NewServer(metrics)
+	defer ts.Close()
+
+	server := NewServer(WithServerMetrics(metrics))
+	server.Register(new(Userservice), "UserService")
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go server.Serve(ln)
+
+	client, err := Dial("tcp", ln.Addr().String(), WithMetrics(metrics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := client.Call("UserService", "Add", []interface{}{1, 2}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client.Call("UserService", "Missing", nil)
+
+	samples := scrape(t, ts.URL)
+	for name, want := range map[string]float64{
+		`rpc_client_requests_total{service="UserService",method="Add",code="OK"}`:                  3,
+		`rpc_client_requests_total{service="UserService",method="Missing",code="NotFound"}`:        1,
+		`rpc_server_requests_total{service="UserService",method="Add",code="OK"}`:                  3,
+		`rpc_server_request_duration_seconds_count{service="UserService",method="Add"}`:            3,
+		`rpc_server_request_duration_seconds_bucket{service="UserService",method="Add",le="+Inf"}`: 3,
+		`rpc_server_in_flight_requests{service="UserService",method="Add"}`:                        0,
+		`rpc_client_connections`:       1,
+		`rpc_client_connections_total`: 1,
+		`rpc_server_connections`:       1,
+	} {
+		if got, ok := samples[name]; !ok || got != want {
+			t.Error(name, got, ok)
+		}
+	}
+	if samples["rpc_client_sent_bytes_total"] == 0 || samples["rpc_client_sent_bytes_total"] != samples["rpc_server_received_bytes_total"] {
+		t.Error(samples["rpc_client_sent_bytes_total"], samples["rpc_server_received_bytes_total"])
+	}
+
+	client.Close()
+	deadline := time.Now().Add(time.Second)
+	for scrape(t, ts.URL)["rpc_server_connections"] != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("服务端连接数没有减少")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := scrape(t, ts.URL)["rpc_client_connections"]; got != 0 {
+		t.Error(got)
+	}
+}
+
+func TestMetricsInFlight(t *testing.T) {
+	metrics := NewPrometheusMetrics(0.01, 1)
+	server := NewServer(WithServerMetrics(metrics))
+	server.Register(new(Userservice), "UserService")
+	client := pipeClient(server)
+	defer client.Close()
+
+	done := make(chan struct{})
+	go func() {
+		client.CallContext(context.Background(), "UserService", "Sleep", []interface{}{50})
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	var b strings.Builder
+	metrics.WriteTo(&b)
+	if !strings.Contains(b.String(), `rpc_server_in_flight_requests{service="UserService",method="Sleep"} 1`) {
+		t.Error(b.String())
+	}
+
+	<-done
+	b.Reset()
+	metrics.WriteTo(&b)
+	for _, line := range []string{
+		"# TYPE rpc_server_request_duration_seconds histogram",
+		`rpc_server_request_duration_seconds_bucket{service="UserService",method="Sleep",le="0.01"} 0`,
+		`rpc_server_request_duration_seconds_bucket{service="UserService",method="Sleep",le="1"} 1`,
+	} {
+		if !strings.Contains(b.String(), line) {
+			t.Error(line)
+		}
+	}
+}
+
+func TestMetricsUnknownMethod(t *testing.T) {
+	metrics := NewPrometheusMetrics()
+	server := NewServer(WithServerMetrics(metrics))
+	server.Register(new(Userservice), "UserService")
+	client := pipeClient(server)
+	defer client.Close()
+
+	for i := 0; i < 10; i++ {
+		client.Call("UserService", "Missing"+strconv.Itoa(i), nil)
+		client.Call("Missing"+strconv.Itoa(i), "Add", nil)
+	}
+
+	var b strings.Builder
+	metrics.WriteTo(&b)
+	if strings.Contains(b.String(), "Missing") {
+		t.Error(b.String())
+	}
+	if !strings.Contains(b.String(), `rpc_server_requests_total{service="unknown",method="unknown",code="NotFound"} 20`) {
+		t.Error(b.String())
+	}
+}
+
+func TestMetricsHTTP(t *testing.T) {
+	metrics := NewPrometheusMetrics()
+	server := NewServer(WithServerMetrics(metrics))
+	server.Register(new(Userservice), "UserService")
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	client, _ := DialHTTP(ts.URL+"/rpc", WithMetrics(metrics))
+	if _, err := client.Call("UserService", "Add", []interface{}{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	metrics.WriteTo(&b)
+	samples := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		if i := strings.LastIndex(line, " "); !strings.HasPrefix(line, "#") {
+			samples[line[:i]] = line[i+1:]
+		}
+	}
+	if samples["rpc_client_sent_bytes_total"] != samples["rpc_server_received_bytes_total"] ||
+		samples["rpc_server_sent_bytes_total"] != samples["rpc_client_received_bytes_total"] {
+		t.Error(b.String())
+	}
+	if samples[`rpc_client_requests_total{service="UserService",method="Add",code="OK"}`] != "1" {
+		t.Error(b.String())
+	}
+}
+
+// 统计字节数的包装不能影响GetConn返回的连接
+func TestMetricsGetConn(t *testing.T) {
+	l := startAddrServer(t)
+	for _, opts := range [][]DialOption{
+		{WithMetrics(NewPrometheusMetrics())},
+		{WithMetrics(NewPrometheusMetrics()), WithReconnect()},
+	} {
+		client, err := Dial("tcp", l.Addr().String(), opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := client.GetConn().(*net.TCPConn); !ok {
+			t.Errorf("%d个选项: %T", len(opts), client.GetConn())
+		}
+		client.Close()
+	}
+}
@@ -0,0 +1,70 @@
+package rpc
+
+// 生成HTTP网关(POST /rpc/{Service}/{Method})的OpenAPI 3.1文档
+func (s *Server) OpenAPI() map[string]any {
+	b := newSchemaBuilder("#/components/schemas/")
+	paths := make(map[string]any)
+
+	for _, name := range s.serviceNames() {
+		s.mu.Lock()
+		srv := s.services[name]
+		s.mu.Unlock()
+
+		for _, m := range b.methods(srv) {
+			paths["/rpc/"+name+"/"+m.Name] = map[string]any{
+				"post": map[string]any{
+					"operationId": name + "." + m.Name,
+					"tags":        []string{name},
+					"requestBody": map[string]any{
+						"required": true,
+						"content":  jsonContent(m.Input),
+					},
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "OK",
+							"content":     jsonContent(responseSchema(m.Output)),
+						},
+						"default": map[string]any{
+							"description": "错误，Code和Error说明了原因",
+							"content":     jsonContent(responseSchema(&JSONSchema{Type: "array"})),
+						},
+					},
+				},
+			}
+		}
+	}
+
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":   "RPC",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": b.defs,
+		},
+	}
+}
+
+func jsonContent(schema *JSONSchema) map[string]any {
+	return map[string]any{
+		"application/json": map[string]any{
+			"schema": schema,
+		},
+	}
+}
+
+func responseSchema(outArgs *JSONSchema) *JSONSchema {
+	return &JSONSchema{
+		Type: "object",
+		Properties: map[string]*JSONSchema{
+			"ServiceName": {Type: "string"},
+			"MethodName":  {Type: "string"},
+			"InArgs":      {Type: "array"},
+			"OutArgs":     outArgs,
+			"Error":       {Type: "string"},
+			"Code":        {Type: "integer"},
+		},
+	}
+}
@@ -0,0 +1,291 @@
+package rpc
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+type dialOptions struct {
+	codec        Codec
+	httpClient   *http.Client
+	tlsConfig    *tls.Config
+	poolSize     int
+	balancer     Balancer
+	minBackoff   time.Duration
+	maxBackoff   time.Duration
+	reconnect    bool
+	stateHook    func(ConnState)
+	retry        map[string]RetryPolicy
+	maxTokens    float64
+	tokenRatio   float64
+	interceptors []ClientInterceptor
+	breaker      *BreakerConfig
+	metrics      Metrics
+	tracer       Tracer
+	// 每次尝试发送前给metadata签名
+	signer func(call *CallInfo, md Metadata) error
+}
+
+type DialOption func(*dialOptions)
+
+func WithCodec(codec Codec) DialOption {
+	return func(o *dialOptions) {
+		o.codec = codec
+	}
+}
+
+// 只对DialHTTP有效
+func WithHTTPClient(client *http.Client) DialOption {
+	return func(o *dialOptions) {
+		o.httpClient = client
+	}
+}
+
+// 对DialWebSocket的wss地址、DialPool和DialMulti有效，DialTLS直接传tls.Config
+func WithTLSConfig(config *tls.Config) DialOption {
+	return func(o *dialOptions) {
+		o.tlsConfig = config
+	}
+}
+
+// 只对DialPool有效，默认4个连接
+func WithPoolSize(n int) DialOption {
+	return func(o *dialOptions) {
+		o.poolSize = n
+	}
+}
+
+// 先加的在外层，重试在所有拦截器里面
+func WithInterceptor(interceptors ...ClientInterceptor) DialOption {
+	return func(o *dialOptions) {
+		o.interceptors = append(o.interceptors, interceptors...)
+	}
+}
+
+// 记录调用次数、耗时、字节数和连接数，比如WithMetrics(rpc.NewPrometheusMetrics())
+func WithMetrics(m Metrics) DialOption {
+	return func(o *dialOptions) {
+		o.metrics = m
+	}
+}
+
+// 每次调用开始一个client span，把traceparent放在metadata里发给服务端
+func WithTracer(t Tracer) DialOption {
+	return func(o *dialOptions) {
+		o.tracer = t
+	}
+}
+
+// 熔断器打开时调用直接返回ErrCircuitOpen，Pool里每个地址分开统计
+func WithCircuitBreaker(config BreakerConfig) DialOption {
+	return func(o *dialOptions) {
+		o.breaker = &config
+	}
+}
+
+// 只对DialPool和DialMulti有效，默认是RoundRobin
+func WithBalancer(balancer Balancer) DialOption {
+	return func(o *dialOptions) {
+		o.balancer = balancer
+	}
+}
+
+// 等于WithBalancer(LeastPending)
+func WithLeastLoaded() DialOption {
+	return WithBalancer(LeastPending)
+}
+
+// 对Dial和DialTLS有效，连接断了之后在后台重连，重连期间的调用返回ErrReconnecting
+func WithReconnect() DialOption {
+	return func(o *dialOptions) {
+		o.reconnect = true
+	}
+}
+
+// 自动重连的客户端连接状态变化时调用
+func WithStateHook(hook func(ConnState)) DialOption {
+	return func(o *dialOptions) {
+		o.stateHook = hook
+	}
+}
+
+// name可以是"Service.Method"、"Service"，空字符串表示所有方法，越具体的越优先
+func WithRetryPolicy(name string, policy RetryPolicy) DialOption {
+	return func(o *dialOptions) {
+		if o.retry == nil {
+			o.retry = make(map[string]RetryPolicy)
+		}
+		o.retry[name] = policy
+	}
+}
+
+// 和gRPC的重试限流一样，每次可重试的失败减1个令牌，每次成功加ratio个，令牌不到一半时不再重试
+func WithRetryBudget(maxTokens, ratio float64) DialOption {
+	return func(o *dialOptions) {
+		o.maxTokens = maxTokens
+		o.tokenRatio = ratio
+	}
+}
+
+// 重连的间隔从min开始每次翻倍，最多到max，再加上随机抖动
+func WithBackoff(min, max time.Duration) DialOption {
+	return func(o *dialOptions) {
+		o.minBackoff = min
+		o.maxBackoff = max
+	}
+}
+
+func newDialOptions(opts []DialOption) dialOptions {
+	o := dialOptions{
+		codec:      JSONCodec,
+		poolSize:   4,
+		balancer:   RoundRobin,
+		minBackoff: 100 * time.Millisecond,
+		maxBackoff: 10 * time.Second,
+		maxTokens:  10,
+		tokenRatio: 0.1,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+type serverOptions struct {
+	codec         Codec
+	tlsConfig     *tls.Config
+	authenticator Authenticator
+	authorizer    Authorizer
+	connLimit     *rateLimit
+	peerLimit     *rateLimit
+	methodLimits  map[string]rateLimit
+	maxConcurrent int
+	metrics       Metrics
+	tracer        Tracer
+	logger        *slog.Logger
+	logArgs       bool
+	redact        Redactor
+	checkOrigin   func(r *http.Request) bool
+	// TLS握手的超时
+	handshakeTimeout time.Duration
+}
+
+type ServerOption func(*serverOptions)
+
+func WithServerCodec(codec Codec) ServerOption {
+	return func(o *serverOptions) {
+		o.codec = codec
+	}
+}
+
+// Serve的时候用TLS包装listener，要求客户端证书的话设置ClientAuth为tls.RequireAndVerifyClientCert
+func WithServerTLS(config *tls.Config) ServerOption {
+	return func(o *serverOptions) {
+		o.tlsConfig = config
+	}
+}
+
+// TLS握手的超时，默认10秒，0表示不限制
+func WithHandshakeTimeout(d time.Duration) ServerOption {
+	return func(o *serverOptions) {
+		o.handshakeTimeout = d
+	}
+}
+
+// 所有调用都要先通过认证，在所有拦截器里面、转换参数之前执行
+func WithAuthenticator(a Authenticator) ServerOption {
+	return func(o *serverOptions) {
+		o.authenticator = a
+	}
+}
+
+// 在认证之后执行，比如WithAuthorizer(policy)
+func WithAuthorizer(a Authorizer) ServerOption {
+	return func(o *serverOptions) {
+		o.authorizer = a
+	}
+}
+
+// 每个连接每秒最多rate个请求，最多攒burst个，对HTTP不起作用
+// rate要大于0，burst至少是1，否则panic
+func WithConnRateLimit(rate float64, burst int) ServerOption {
+	limit := newRateLimit(rate, burst)
+	return func(o *serverOptions) {
+		o.connLimit = &limit
+	}
+}
+
+// 同一个IP的所有连接共用一个限制
+func WithPeerRateLimit(rate float64, burst int) ServerOption {
+	limit := newRateLimit(rate, burst)
+	return func(o *serverOptions) {
+		o.peerLimit = &limit
+	}
+}
+
+// method是"Service.Method"，所有调用方共用一个限制
+func WithMethodRateLimit(method string, rate float64, burst int) ServerOption {
+	limit := newRateLimit(rate, burst)
+	return func(o *serverOptions) {
+		if o.methodLimits == nil {
+			o.methodLimits = make(map[string]rateLimit)
+		}
+		o.methodLimits[method] = limit
+	}
+}
+
+// 同时处理的调用最多n个，超过的直接返回ResourceExhausted，不排队
+func WithMaxConcurrent(n int) ServerOption {
+	return func(o *serverOptions) {
+		o.maxConcurrent = n
+	}
+}
+
+// 可以和客户端共用一个Metrics，指标名按client和server分开
+func WithServerMetrics(m Metrics) ServerOption {
+	return func(o *serverOptions) {
+		o.metrics = m
+	}
+}
+
+// 调用服务的方法时开始一个server span，父span是客户端发来的traceparent
+func WithServerTracer(t Tracer) ServerOption {
+	return func(o *serverOptions) {
+		o.tracer = t
+	}
+}
+
+// 每次调用记一条访问日志，连接的打开、关闭和解码错误单独记录，默认不记日志
+func WithLogger(logger *slog.Logger) ServerOption {
+	return func(o *serverOptions) {
+		o.logger = logger
+	}
+}
+
+// 访问日志里带上参数，redact为nil时原样记录，比如WithLogArgs(RedactFields("password"))
+func WithLogArgs(redact Redactor) ServerOption {
+	return func(o *serverOptions) {
+		o.logArgs = true
+		o.redact = redact
+	}
+}
+
+// WebSocket升级时检查Origin，返回false的回403，默认只允许和Host相同的Origin，没有Origin的请求不是浏览器发的，都允许
+func WithCheckOrigin(check func(r *http.Request) bool) ServerOption {
+	return func(o *serverOptions) {
+		o.checkOrigin = check
+	}
+}
+
+func newServerOptions(opts []ServerOption) serverOptions {
+	o := serverOptions{
+		codec:            JSONCodec,
+		handshakeTimeout: 10 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
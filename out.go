@@ -1,7 +1,15 @@
 package rpc
 
+import "errors"
+
 type Out struct {
 	outArgs []any
+	codec   Codec
+}
+
+// 不经过网络构造返回值，比如在客户端拦截器里mock，Decode用JSONCodec转换
+func NewOut(outArgs ...any) *Out {
+	return &Out{outArgs, JSONCodec}
 }
 
 func (o *Out) Len() int {
@@ -11,3 +19,10 @@ func (o *Out) Len() int {
 func (o *Out) Get(index int) any {
 	return o.outArgs[index]
 }
+
+func (o *Out) Decode(index int, v any) error {
+	if index < 0 || index >= len(o.outArgs) {
+		return errors.New("返回值下标越界")
+	}
+	return o.codec.Convert(o.outArgs[index], v)
+}
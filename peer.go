@@ -0,0 +1,85 @@
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"time"
+)
+
+type Peer struct {
+	Addr net.Addr
+	// 不是TLS连接时为nil
+	TLS *tls.ConnectionState
+	// 校验通过的客户端证书的身份，CommonName优先，其次是URI、DNS、Email
+	Identity string
+}
+
+type peerKey struct{}
+
+func withPeer(ctx context.Context, p *Peer) context.Context {
+	return context.WithValue(ctx, peerKey{}, p)
+}
+
+func PeerFromContext(ctx context.Context) (*Peer, bool) {
+	p, ok := ctx.Value(peerKey{}).(*Peer)
+	return p, ok
+}
+
+// 握手超过timeout就放弃，不然不发数据的连接会一直占着，timeout是0时不限制
+func newPeer(conn net.Conn, timeout time.Duration) *Peer {
+	p := &Peer{Addr: conn.RemoteAddr()}
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		if timeout > 0 {
+			tlsConn.SetDeadline(time.Now().Add(timeout))
+		}
+		err := tlsConn.Handshake()
+		tlsConn.SetDeadline(time.Time{})
+		if err == nil {
+			state := tlsConn.ConnectionState()
+			p.setTLS(&state)
+		}
+	}
+	return p
+}
+
+func newHTTPPeer(r *http.Request) *Peer {
+	p := &Peer{Addr: addrString(r.RemoteAddr)}
+	if r.TLS != nil {
+		p.setTLS(r.TLS)
+	}
+	return p
+}
+
+func (p *Peer) setTLS(state *tls.ConnectionState) {
+	p.TLS = state
+	if len(state.VerifiedChains) > 0 && len(state.VerifiedChains[0]) > 0 {
+		p.Identity = certIdentity(state.VerifiedChains[0][0])
+	}
+}
+
+func certIdentity(cert *x509.Certificate) string {
+	switch {
+	case cert.Subject.CommonName != "":
+		return cert.Subject.CommonName
+	case len(cert.URIs) > 0:
+		return cert.URIs[0].String()
+	case len(cert.DNSNames) > 0:
+		return cert.DNSNames[0]
+	case len(cert.EmailAddresses) > 0:
+		return cert.EmailAddresses[0]
+	}
+	return ""
+}
+
+type addrString string
+
+func (a addrString) Network() string {
+	return "tcp"
+}
+
+func (a addrString) String() string {
+	return string(a)
+}
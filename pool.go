@@ -0,0 +1,288 @@
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Pool在一组连接之间做负载均衡，坏掉的连接先摘掉，在后台按退避时间重连，连上了再放回来
+type Pool struct {
+	opts     dialOptions
+	retry    *retrier
+	breakers *breakers
+	picker   picker
+
+	mu     sync.Mutex
+	conns  []*poolConn
+	closed bool
+	done   chan struct{}
+	// 停止resolver
+	cancel context.CancelFunc
+}
+
+type poolConn struct {
+	network string
+	address string
+	client  *Client
+	pending int
+	// 地址已经不在resolver的结果里了，调用都结束后关闭
+	removed bool
+}
+
+// 对同一个地址保持多个连接
+func DialPool(network, address string, opts ...DialOption) (*Pool, error) {
+	o := newDialOptions(opts)
+	if o.poolSize < 1 {
+		o.poolSize = 1
+	}
+	var conns []*poolConn
+	for i := 0; i < o.poolSize; i++ {
+		conns = append(conns, &poolConn{network: network, address: address})
+	}
+	return newPool(conns, o)
+}
+
+// 每个地址一个连接，地址可以带network，比如unix:///tmp/rpc.sock，没有的话是tcp
+func DialMulti(addrs []string, opts ...DialOption) (*Pool, error) {
+	if len(addrs) == 0 {
+		return nil, errors.New("没有地址")
+	}
+	var conns []*poolConn
+	for _, addr := range addrs {
+		network, address := splitAddress(addr)
+		conns = append(conns, &poolConn{network: network, address: address})
+	}
+	return newPool(conns, newDialOptions(opts))
+}
+
+func splitAddress(addr string) (string, string) {
+	if i := strings.Index(addr, "://"); i >= 0 {
+		return addr[:i], addr[i+3:]
+	}
+	return "tcp", addr
+}
+
+// 一个都连不上才返回错误，连不上的在后台重连
+func newPool(conns []*poolConn, o dialOptions) (*Pool, error) {
+	p := &Pool{
+		opts:     o,
+		retry:    newRetrier(o),
+		breakers: newBreakers(o.breaker),
+		picker:   o.balancer.newPicker(),
+		conns:    conns,
+		done:     make(chan struct{}),
+	}
+
+	var firstErr error
+	for _, pc := range p.conns {
+		client, err := p.dial(pc)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		pc.client = client
+	}
+	if firstErr != nil {
+		for _, pc := range p.conns {
+			if pc.client != nil {
+				firstErr = nil
+				break
+			}
+		}
+		if firstErr != nil {
+			p.Close()
+			return nil, firstErr
+		}
+	}
+	for _, pc := range p.conns {
+		if pc.client == nil {
+			go p.redial(pc)
+		}
+	}
+	return p, nil
+}
+
+func (p *Pool) dial(pc *poolConn) (*Client, error) {
+	var conn net.Conn
+	var err error
+	if p.opts.tlsConfig != nil {
+		conn, err = tls.Dial(pc.network, pc.address, p.opts.tlsConfig)
+	} else {
+		conn, err = net.Dial(pc.network, pc.address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	client := NewClientWithConn(conn, WithCodec(p.opts.codec), WithMetrics(p.opts.metrics), WithTracer(p.opts.tracer))
+	client.retry = p.retry
+	client.breakers = p.breakers
+	client.signer = p.opts.signer
+	client.endpoint = pc.network + "://" + pc.address
+	return client, nil
+}
+
+func (p *Pool) Call(serviceName, methodName string, inArgs []any) (*Out, error) {
+	return p.CallContext(context.Background(), serviceName, methodName, inArgs)
+}
+
+// 重试的时候会重新选一个连接
+func (p *Pool) CallContext(ctx context.Context, serviceName, methodName string, inArgs []any) (*Out, error) {
+	invoke := chainInvoker(p.opts.interceptors, func(ctx context.Context, call *CallInfo) (*Out, error) {
+		if err := checkArgs(call.InArgs); err != nil {
+			return nil, err
+		}
+		pick := &pickCall{ctx: ctx, serviceName: call.ServiceName, methodName: call.MethodName, inArgs: call.InArgs}
+		return p.retry.do(ctx, call.ServiceName, call.MethodName, func() (*Out, error) {
+			pc, client, err := p.get(pick)
+			if err != nil {
+				return nil, err
+			}
+			out, err := client.call(ctx, call)
+			p.put(pc, client)
+			return out, err
+		})
+	})
+	return invoke(ctx, newCallInfo(ctx, serviceName, methodName, inArgs))
+}
+
+func (p *Pool) get(call *pickCall) (*poolConn, *Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, nil, ErrShutdown
+	}
+
+	var picked *poolConn
+	if len(p.conns) > 0 {
+		picked = p.picker.pick(p.conns, call)
+	}
+	if picked == nil {
+		return nil, nil, &Error{Code: CodeUnavailable, Message: "没有可用的连接"}
+	}
+	picked.pending++
+	return picked, picked.client, nil
+}
+
+func (p *Pool) put(pc *poolConn, client *Client) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pc.pending--
+	switch {
+	case pc.removed:
+		if pc.pending == 0 && pc.client != nil {
+			pc.client.Close()
+			pc.client = nil
+		}
+	case pc.client == client && client.transport.broken():
+		pc.client = nil
+		client.Close()
+		if !p.closed {
+			go p.redial(pc)
+		}
+	}
+}
+
+// 把连接换成新的地址列表，已有的地址保留原来的连接，新的地址在后台连接
+func (p *Pool) update(addrs []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+
+	old := make(map[string]*poolConn)
+	for _, pc := range p.conns {
+		old[pc.network+"://"+pc.address] = pc
+	}
+
+	var conns []*poolConn
+	for _, addr := range addrs {
+		network, address := splitAddress(addr)
+		key := network + "://" + address
+		if pc, ok := old[key]; ok {
+			conns = append(conns, pc)
+			delete(old, key)
+			continue
+		}
+		pc := &poolConn{network: network, address: address}
+		conns = append(conns, pc)
+		go p.connect(pc)
+	}
+	p.conns = conns
+
+	for _, pc := range old {
+		pc.removed = true
+		if pc.pending == 0 && pc.client != nil {
+			pc.client.Close()
+			pc.client = nil
+		}
+	}
+}
+
+func (p *Pool) connect(pc *poolConn) {
+	client, err := p.dial(pc)
+	if err != nil {
+		p.redial(pc)
+		return
+	}
+	p.setClient(pc, client)
+}
+
+func (p *Pool) redial(pc *poolConn) {
+	for attempt := 0; ; attempt++ {
+		select {
+		case <-p.done:
+			return
+		case <-time.After(backoff(p.opts.minBackoff, p.opts.maxBackoff, attempt)):
+		}
+
+		p.mu.Lock()
+		removed := pc.removed
+		p.mu.Unlock()
+		if removed {
+			return
+		}
+
+		client, err := p.dial(pc)
+		if err == nil {
+			p.setClient(pc, client)
+			return
+		}
+	}
+}
+
+func (p *Pool) setClient(pc *poolConn, client *Client) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed || pc.removed {
+		client.Close()
+		return
+	}
+	pc.client = client
+}
+
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	close(p.done)
+	if p.cancel != nil {
+		p.cancel()
+	}
+	for _, pc := range p.conns {
+		if pc.client != nil {
+			pc.client.Close()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+type Addr struct{}
+
+func (a *Addr) Remote(ctx context.Context) string {
+	p, _ := PeerFromContext(ctx)
+	return p.Addr.String()
+}
+
+func (a *Addr) Slow(ctx context.Context, ms int) string {
+	time.Sleep(time.Duration(ms) * time.Millisecond)
+	return a.Remote(ctx)
+}
+
+// 记下所有连接，方便测试里把它们都断掉
+type trackListener struct {
+	net.Listener
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func (l *trackListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.mu.Lock()
+		l.conns = append(l.conns, conn)
+		l.mu.Unlock()
+	}
+	return conn, err
+}
+
+func (l *trackListener) closeConns() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, conn := range l.conns {
+		conn.Close()
+	}
+	l.conns = nil
+}
+
+func startAddrServer(t *testing.T) *trackListener {
+	server := NewServer()
+	server.Register(new(Addr), "Addr")
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &trackListener{Listener: ln}
+	go server.Serve(l)
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+func TestPoolRoundRobin(t *testing.T) {
+	l := startAddrServer(t)
+	pool, err := DialPool("tcp", l.Addr().String(), WithPoolSize(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	seen := make(map[string]bool)
+	for i := 0; i < 6; i++ {
+		addr, err := Call1[string](context.Background(), pool, "Addr", "Remote")
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[addr] = true
+	}
+	if len(seen) != 3 {
+		t.Error(seen)
+	}
+}
+
+func TestPoolLeastLoaded(t *testing.T) {
+	l := startAddrServer(t)
+	pool, err := DialPool("tcp", l.Addr().String(), WithPoolSize(3), WithLeastLoaded())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	var wg sync.WaitGroup
+	slow := make([]string, 2)
+	for i := range slow {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			slow[i], _ = Call1[string](context.Background(), pool, "Addr", "Slow", 200)
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	addr, err := Call1[string](context.Background(), pool, "Addr", "Remote")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	if addr == slow[0] || addr == slow[1] || slow[0] == slow[1] {
+		t.Error(addr, slow)
+	}
+}
+
+func TestPoolRedial(t *testing.T) {
+	l := startAddrServer(t)
+	pool, err := DialPool("tcp", l.Addr().String(), WithPoolSize(2), WithBackoff(10*time.Millisecond, 50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	l.closeConns()
+
+	deadline := time.Now().Add(2 * time.Second)
+	failed := 0
+	for {
+		_, err := Call1[string](context.Background(), pool, "Addr", "Remote")
+		if err == nil {
+			break
+		}
+		failed++
+		if time.Now().After(deadline) {
+			t.Fatal(err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if failed == 0 {
+		t.Error("expected broken connections to fail")
+	}
+
+	// 两个连接都重连好了
+	time.Sleep(100 * time.Millisecond)
+	for i := 0; i < 4; i++ {
+		if _, err := Call1[string](context.Background(), pool, "Addr", "Remote"); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestPoolClose(t *testing.T) {
+	l := startAddrServer(t)
+	pool, err := DialPool("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.Close()
+	if _, err := pool.Call("Addr", "Remote", []interface{}{}); err != ErrShutdown {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,171 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+var ErrReconnecting = errors.New("正在重连")
+
+type ConnState int
+
+const (
+	StateReady ConnState = iota
+	StateReconnecting
+	StateShutdown
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case StateReady:
+		return "Ready"
+	case StateReconnecting:
+		return "Reconnecting"
+	case StateShutdown:
+		return "Shutdown"
+	}
+	return fmt.Sprintf("ConnState(%d)", int(s))
+}
+
+// 指数退避，在[d/2, d)之间随机，避免所有客户端同时重连
+func backoff(min, max time.Duration, attempt int) time.Duration {
+	d := min
+	for i := 0; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	if d <= 1 {
+		return d
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)))
+}
+
+// 连接断了就换一个streamTransport，断开期间的调用直接失败，不排队
+type reconnectTransport struct {
+	dialer func() (net.Conn, error)
+	opts   dialOptions
+
+	mu     sync.Mutex
+	cur    *streamTransport
+	closed bool
+	done   chan struct{}
+}
+
+func newReconnectTransport(conn net.Conn, dialer func() (net.Conn, error), opts dialOptions) *reconnectTransport {
+	return &reconnectTransport{
+		dialer: dialer,
+		opts:   opts,
+		cur:    newStreamTransport(conn, opts),
+		done:   make(chan struct{}),
+	}
+}
+
+func (r *reconnectTransport) roundTrip(ctx context.Context, req *param, resp *param) error {
+	r.mu.Lock()
+	t := r.cur
+	closed := r.closed
+	r.mu.Unlock()
+	if closed {
+		return ErrShutdown
+	}
+	if t == nil {
+		return ErrReconnecting
+	}
+
+	err := t.roundTrip(ctx, req, resp)
+	if err != nil && t.broken() {
+		r.reconnect(t)
+		if ctx.Err() == nil {
+			return fmt.Errorf("%w: %v", ErrReconnecting, err)
+		}
+	}
+	return err
+}
+
+func (r *reconnectTransport) reconnect(t *streamTransport) {
+	r.mu.Lock()
+	if r.closed || r.cur != t {
+		r.mu.Unlock()
+		return
+	}
+	r.cur = nil
+	r.mu.Unlock()
+
+	t.close()
+	r.notify(StateReconnecting)
+	go r.redial()
+}
+
+func (r *reconnectTransport) redial() {
+	for attempt := 0; ; attempt++ {
+		select {
+		case <-r.done:
+			return
+		case <-time.After(backoff(r.opts.minBackoff, r.opts.maxBackoff, attempt)):
+		}
+
+		conn, err := r.dialer()
+		if err != nil {
+			continue
+		}
+
+		r.mu.Lock()
+		if r.closed {
+			r.mu.Unlock()
+			conn.Close()
+			return
+		}
+		r.cur = newStreamTransport(conn, r.opts)
+		r.mu.Unlock()
+		r.notify(StateReady)
+		return
+	}
+}
+
+func (r *reconnectTransport) notify(state ConnState) {
+	if r.opts.stateHook != nil {
+		r.opts.stateHook(state)
+	}
+}
+
+func (r *reconnectTransport) broken() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cur == nil
+}
+
+func (r *reconnectTransport) rawConn() net.Conn {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cur == nil {
+		return nil
+	}
+	return r.cur.rawConn()
+}
+
+func (r *reconnectTransport) close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	close(r.done)
+	t := r.cur
+	r.cur = nil
+	r.mu.Unlock()
+
+	var err error
+	if t != nil {
+		err = t.close()
+	}
+	r.notify(StateShutdown)
+	return err
+}
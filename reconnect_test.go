@@ -0,0 +1,87 @@
+package rpc
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestReconnect(t *testing.T) {
+	server := NewServer()
+	server.Register(new(Userservice), "UserService")
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := ln.Addr().String()
+	l := &trackListener{Listener: ln}
+	go server.Serve(l)
+
+	states := make(chan ConnState, 10)
+	client, err := Dial("tcp", addr,
+		WithReconnect(),
+		WithBackoff(10*time.Millisecond, 50*time.Millisecond),
+		WithStateHook(func(s ConnState) { states <- s }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Call("UserService", "Add", []interface{}{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 服务端重启
+	l.Close()
+	l.closeConns()
+
+	_, err = client.Call("UserService", "Add", []interface{}{1, 2})
+	if !errors.Is(err, ErrReconnecting) {
+		t.Fatal(err)
+	}
+	if s := <-states; s != StateReconnecting {
+		t.Error(s)
+	}
+	_, err = client.Call("UserService", "Add", []interface{}{1, 2})
+	if err != ErrReconnecting {
+		t.Error(err)
+	}
+
+	ln, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go server.Serve(ln)
+
+	select {
+	case s := <-states:
+		if s != StateReady {
+			t.Error(s)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("reconnect timeout")
+	}
+	out, err := client.Call("UserService", "Add", []interface{}{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Get(0) != float64(3) {
+		t.Error(out.Get(0))
+	}
+
+	client.Close()
+	if s := <-states; s != StateShutdown {
+		t.Error(s)
+	}
+	if _, err := client.Call("UserService", "Add", []interface{}{1, 2}); err != ErrShutdown {
+		t.Error(err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	min, max := 100*time.Millisecond, time.Second
+	for attempt, want := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		want *= time.Millisecond
+		d := backoff(min, max, attempt)
+		if d < want/2 || d >= want {
+			t.Error(attempt, d)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package rpc
+
+const ReflectionServiceName = "rpc.Reflection"
+
+type reflectionService struct {
+	server *Server
+}
+
+func (r *reflectionService) ListServices() []string {
+	return r.server.serviceNames()
+}
+
+func (r *reflectionService) Describe(name string) *ServiceSchema {
+	schema, err := r.server.ServiceSchema(name)
+	if err != nil {
+		return nil
+	}
+	return schema
+}
+
+// 注册一个内置的反射服务，客户端可以查询服务端注册了哪些服务和方法
+func (s *Server) EnableReflection() {
+	s.Register(&reflectionService{server: s}, ReflectionServiceName)
+}
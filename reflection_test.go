@@ -0,0 +1,51 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+func TestReflection(t *testing.T) {
+	server := NewServer()
+	server.Register(new(Userservice), "UserService")
+	server.EnableReflection()
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go server.ServeConn(conn)
+		}
+	}()
+
+	client, _ := Dial("tcp", l.Addr().String())
+	names, err := Call1[[]string](context.Background(), client, ReflectionServiceName, "ListServices")
+	if err != nil {
+		t.Error(err)
+	}
+	if len(names) != 2 || names[0] != "UserService" || names[1] != ReflectionServiceName {
+		t.Error(names)
+	}
+
+	schema, err := Call1[*ServiceSchema](context.Background(), client, ReflectionServiceName, "Describe", "UserService")
+	if err != nil {
+		t.Error(err)
+	}
+	if schema == nil || schema.Name != "UserService" || findMethodSchema(schema, "Add") == nil {
+		t.Error(schema)
+	}
+
+	schema, err = Call1[*ServiceSchema](context.Background(), client, ReflectionServiceName, "Describe", "UserServicee")
+	if err != nil {
+		t.Error(err)
+	}
+	if schema != nil {
+		t.Error(schema)
+	}
+
+	client.Close()
+	l.Close()
+}
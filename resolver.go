@@ -0,0 +1,313 @@
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Resolver把服务的地址推给客户端
+type Resolver interface {
+	// 先推送一次当前的地址，之后地址变化时再推送，直到ctx结束
+	// 查询失败或者没有地址时继续用之前的地址，不推送
+	Resolve(ctx context.Context, update func(addrs []string)) error
+}
+
+// 地址由Resolver决定，地址变化时增减连接，不用重启调用方
+func DialResolver(r Resolver, opts ...DialOption) (*Pool, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &resolverWatcher{first: make(chan []string, 1)}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- r.Resolve(ctx, w.update)
+	}()
+
+	var addrs []string
+	select {
+	case addrs = <-w.first:
+	case err := <-errc:
+		cancel()
+		if err == nil {
+			err = errors.New("没有解析到地址")
+		}
+		return nil, err
+	}
+
+	var conns []*poolConn
+	for _, addr := range addrs {
+		network, address := splitAddress(addr)
+		conns = append(conns, &poolConn{network: network, address: address})
+	}
+	p, err := newPool(conns, newDialOptions(opts))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	p.cancel = cancel
+	w.setPool(p)
+	return p, nil
+}
+
+// Pool创建好之前收到的更新先存起来
+type resolverWatcher struct {
+	mu     sync.Mutex
+	first  chan []string
+	sent   bool
+	latest []string
+	pool   *Pool
+}
+
+func (w *resolverWatcher) update(addrs []string) {
+	w.mu.Lock()
+	if w.pool == nil {
+		if !w.sent {
+			w.sent = true
+			w.first <- addrs
+		} else {
+			w.latest = addrs
+		}
+		w.mu.Unlock()
+		return
+	}
+	p := w.pool
+	w.mu.Unlock()
+	p.update(addrs)
+}
+
+func (w *resolverWatcher) setPool(p *Pool) {
+	w.mu.Lock()
+	w.pool = p
+	latest := w.latest
+	w.mu.Unlock()
+	if latest != nil {
+		p.update(latest)
+	}
+}
+
+type staticResolver struct {
+	addrs []string
+}
+
+// 固定的地址列表
+func StaticResolver(addrs ...string) Resolver {
+	return &staticResolver{addrs: addrs}
+}
+
+func (r *staticResolver) Resolve(ctx context.Context, update func([]string)) error {
+	update(r.addrs)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// 定时查询，地址变了才推送
+type pollResolver struct {
+	interval time.Duration
+	lookup   func(ctx context.Context) ([]string, error)
+}
+
+// 没有地址时不推送，不然所有连接都会被摘掉，继续用之前的地址
+func (r *pollResolver) Resolve(ctx context.Context, update func([]string)) error {
+	addrs, err := r.lookup(ctx)
+	if err != nil {
+		return err
+	}
+	if len(addrs) == 0 {
+		return errors.New("没有解析到地址")
+	}
+	update(addrs)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		next, err := r.lookup(ctx)
+		if err != nil || len(next) == 0 || equalAddrs(addrs, next) {
+			continue
+		}
+		addrs = next
+		update(addrs)
+	}
+}
+
+func equalAddrs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+var lookupSRV = net.DefaultResolver.LookupSRV
+
+// 每隔interval查询一次_service._proto.name的SRV记录，比如DNSSRVResolver("rpc", "tcp", "example.com", 30*time.Second)
+func DNSSRVResolver(service, proto, name string, interval time.Duration) Resolver {
+	return &pollResolver{
+		interval: interval,
+		lookup: func(ctx context.Context) ([]string, error) {
+			_, records, err := lookupSRV(ctx, service, proto, name)
+			if err != nil {
+				return nil, err
+			}
+			var addrs []string
+			for _, srv := range records {
+				host := strings.TrimSuffix(srv.Target, ".")
+				addrs = append(addrs, net.JoinHostPort(host, strconv.Itoa(int(srv.Port))))
+			}
+			sort.Strings(addrs)
+			return addrs, nil
+		},
+	}
+}
+
+// 每隔interval读一次文件，.yaml和.yml按YAML解析，其他按JSON解析
+// JSON可以是["host:port", ...]或者{"endpoints": ["host:port", ...]}
+// YAML只支持字符串列表，可以放在endpoints下面，具体见parseYAMLEndpoints
+func FileResolver(path string, interval time.Duration) Resolver {
+	return &pollResolver{
+		interval: interval,
+		lookup: func(ctx context.Context) ([]string, error) {
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			switch strings.ToLower(filepath.Ext(path)) {
+			case ".yaml", ".yml":
+				return parseYAMLEndpoints(b)
+			}
+			return parseJSONEndpoints(b)
+		},
+	}
+}
+
+func parseJSONEndpoints(b []byte) ([]string, error) {
+	b = bytes.TrimSpace(b)
+	if len(b) > 0 && b[0] == '{' {
+		var v struct {
+			Endpoints []string `json:"endpoints"`
+		}
+		err := json.Unmarshal(b, &v)
+		return v.Endpoints, err
+	}
+	var addrs []string
+	err := json.Unmarshal(b, &addrs)
+	return addrs, err
+}
+
+// 只支持下面这种写法，endpoints:这一行可以不写，#开始的是注释，不认识的行返回错误
+//
+//	endpoints:
+//	  - 10.0.0.1:1234
+//	  - "10.0.0.2:1234"
+//	  - '10.0.0.3:1234'
+//
+// 每项是一个地址，可以用单引号或者双引号括起来，同一个列表的缩进要一样
+func parseYAMLEndpoints(b []byte) ([]string, error) {
+	addrs := []string{}
+	indent := -1
+	header := false
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		trimmed := strings.TrimLeft(line, " ")
+		fail := func(reason string) ([]string, error) {
+			return nil, errors.New("第" + strconv.Itoa(n) + "行" + reason + ": " + trimmed)
+		}
+		switch {
+		case trimmed == "" || strings.HasPrefix(trimmed, "#"):
+		case line == "---" && !header && indent < 0:
+		case strings.TrimSpace(stripYAMLComment(line)) == "endpoints:" && line[0] != ' ' && !header && indent < 0:
+			header = true
+		case trimmed == "-" || strings.HasPrefix(trimmed, "- "):
+			if i := len(line) - len(trimmed); indent < 0 {
+				indent = i
+			} else if i != indent {
+				return fail("缩进不对")
+			}
+			addr, err := parseYAMLScalar(strings.TrimSpace(trimmed[1:]))
+			if err != nil {
+				return fail(err.Error())
+			}
+			addrs = append(addrs, addr)
+		default:
+			return fail("格式不对")
+		}
+	}
+	return addrs, scanner.Err()
+}
+
+// 地址不能是空的，也不能是map或者嵌套的列表
+func parseYAMLScalar(s string) (string, error) {
+	if s == "" || s[0] == '#' {
+		return "", errors.New("地址是空的")
+	}
+	switch s[0] {
+	case '"':
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '"':
+				if !isYAMLComment(s[i+1:]) {
+					return "", errors.New("引号不匹配")
+				}
+				return strconv.Unquote(s[:i+1])
+			}
+		}
+		return "", errors.New("引号不匹配")
+	case '\'':
+		var b strings.Builder
+		for i := 1; i < len(s); i++ {
+			if s[i] != '\'' {
+				b.WriteByte(s[i])
+				continue
+			}
+			if i+1 < len(s) && s[i+1] == '\'' {
+				b.WriteByte('\'')
+				i++
+				continue
+			}
+			if !isYAMLComment(s[i+1:]) {
+				break
+			}
+			return b.String(), nil
+		}
+		return "", errors.New("引号不匹配")
+	case '[', '{', '-', '&', '*', '!', '|', '>', '%', '@', '`':
+		return "", errors.New("不支持的写法")
+	}
+	s = strings.TrimSpace(stripYAMLComment(s))
+	if strings.Contains(s, ": ") || strings.HasSuffix(s, ":") {
+		return "", errors.New("不支持的写法")
+	}
+	return s, nil
+}
+
+func stripYAMLComment(s string) string {
+	if i := strings.Index(s, " #"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+func isYAMLComment(s string) bool {
+	s = strings.TrimSpace(s)
+	return s == "" || s[0] == '#'
+}
@@ -0,0 +1,184 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStaticResolver(t *testing.T) {
+	_, addrs := startNamedServers(t, 2)
+	pool, err := DialResolver(StaticResolver(addrs...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	seen := make(map[string]bool)
+	for i := 0; i < 4; i++ {
+		name, err := callName(pool)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[name] = true
+	}
+	if len(seen) != 2 {
+		t.Error(seen)
+	}
+}
+
+// 等到调用只落在want上
+func waitNames(t *testing.T, pool *Pool, want ...string) {
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		seen := make(map[string]bool)
+		for i := 0; i < 2*len(want); i++ {
+			if name, err := callName(pool); err == nil {
+				seen[name] = true
+			}
+		}
+		if fmt.Sprint(seen) == fmt.Sprint(setOf(want)) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal(seen, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func setOf(names []string) map[string]bool {
+	m := make(map[string]bool)
+	for _, name := range names {
+		m[name] = true
+	}
+	return m
+}
+
+func TestFileResolver(t *testing.T) {
+	_, addrs := startNamedServers(t, 2)
+	for _, ext := range []string{".json", ".yaml"} {
+		path := filepath.Join(t.TempDir(), "endpoints"+ext)
+		write := func(addrs ...string) {
+			var content string
+			if ext == ".json" {
+				content = fmt.Sprintf(`{"endpoints": ["%s"]}`, addrs[0])
+				if len(addrs) > 1 {
+					content = fmt.Sprintf(`["%s", "%s"]`, addrs[0], addrs[1])
+				}
+			} else {
+				content = "# rpc\nendpoints:\n"
+				for _, addr := range addrs {
+					content += "  - \"" + addr + "\"\n"
+				}
+			}
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		write(addrs[0])
+		pool, err := DialResolver(FileResolver(path, 10*time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		waitNames(t, pool, "s0")
+
+		write(addrs[0], addrs[1])
+		waitNames(t, pool, "s0", "s1")
+
+		write(addrs[1])
+		waitNames(t, pool, "s1")
+
+		// 文件内容不对或者没有地址时继续用之前的地址
+		for _, content := range []string{"{", "[]", "endpoints:\n"} {
+			os.WriteFile(path, []byte(content), 0644)
+			time.Sleep(30 * time.Millisecond)
+			waitNames(t, pool, "s1")
+		}
+
+		pool.Close()
+	}
+
+	// 一开始就没有地址
+	path := filepath.Join(t.TempDir(), "empty.json")
+	os.WriteFile(path, []byte("[]"), 0644)
+	if _, err := DialResolver(FileResolver(path, time.Second)); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestDNSSRVResolver(t *testing.T) {
+	defer func(f func(context.Context, string, string, string) (string, []*net.SRV, error)) {
+		lookupSRV = f
+	}(lookupSRV)
+
+	_, addrs := startNamedServers(t, 2)
+	var ports []uint16
+	for _, addr := range addrs {
+		_, port, _ := net.SplitHostPort(addr)
+		var p uint16
+		fmt.Sscan(port, &p)
+		ports = append(ports, p)
+	}
+
+	records := make(chan []*net.SRV, 1)
+	records <- []*net.SRV{{Target: "127.0.0.1.", Port: ports[0]}}
+	var current []*net.SRV
+	lookupSRV = func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+		if service != "rpc" || proto != "tcp" || name != "example.com" {
+			t.Error(service, proto, name)
+		}
+		select {
+		case current = <-records:
+		default:
+		}
+		return "", current, nil
+	}
+
+	pool, err := DialResolver(DNSSRVResolver("rpc", "tcp", "example.com", 10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	waitNames(t, pool, "s0")
+
+	records <- []*net.SRV{{Target: "127.0.0.1.", Port: ports[1]}}
+	waitNames(t, pool, "s1")
+}
+
+func TestParseYAMLEndpoints(t *testing.T) {
+	for content, want := range map[string]string{
+		"---\n- 10.0.0.1:1234 # a\n- '10.0.0.2:1234'\n":          "[10.0.0.1:1234 10.0.0.2:1234]",
+		"# rpc\nendpoints:\n  - \"a:1\"  # x\n\n  - 'it''s:2'\n": "[a:1 it's:2]",
+		"endpoints: # 地址\n- unix:///tmp/rpc.sock\n":              "[unix:///tmp/rpc.sock]",
+		"endpoints:\n": "[]",
+	} {
+		addrs, err := parseYAMLEndpoints([]byte(content))
+		if err != nil || fmt.Sprint(addrs) != want {
+			t.Error(content, addrs, err)
+		}
+	}
+
+	for _, content := range []string{
+		"endpoints: [a]\n",
+		"servers:\n  - a:1\n",
+		"endpoints:\n  - host: a\n    port: 1\n",
+		"endpoints:\n  -\n",
+		"endpoints:\n  - a:1\n    - b:1\n",
+		"endpoints:\n  - - a:1\n",
+		"endpoints:\n  - \"a:1\n",
+		"endpoints:\n  - 'a:1' b\n",
+		"- a:1\nendpoints:\n",
+		"  endpoints:\n  - a:1\n",
+		"a:1\n",
+	} {
+		if addrs, err := parseYAMLEndpoints([]byte(content)); err == nil {
+			t.Errorf("%q %v", content, addrs)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+type RetryPolicy struct {
+	// 包括第一次调用在内最多调用几次
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	// 可以重试的错误码，为空时只重试Unavailable，连接断开等网络错误也算Unavailable
+	Codes []Code
+}
+
+// 只有服务端标记为幂等的方法才会重试，是否幂等从之前的响应里得知，还不知道的当作不幂等
+type retrier struct {
+	policies   map[string]RetryPolicy
+	maxTokens  float64
+	tokenRatio float64
+
+	mu         sync.Mutex
+	tokens     float64
+	idempotent map[string]bool
+}
+
+func newRetrier(o dialOptions) *retrier {
+	return &retrier{
+		policies:   o.retry,
+		maxTokens:  o.maxTokens,
+		tokenRatio: o.tokenRatio,
+		tokens:     o.maxTokens,
+		idempotent: make(map[string]bool),
+	}
+}
+
+func (r *retrier) policy(serviceName, methodName string) (RetryPolicy, bool) {
+	for _, name := range []string{serviceName + "." + methodName, serviceName, ""} {
+		if policy, ok := r.policies[name]; ok {
+			return policy, true
+		}
+	}
+	return RetryPolicy{}, false
+}
+
+func (r *retrier) learn(serviceName, methodName string, idempotent bool) {
+	if len(r.policies) == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.idempotent[serviceName+"."+methodName] = idempotent
+}
+
+func (r *retrier) do(ctx context.Context, serviceName, methodName string, call func() (*Out, error)) (*Out, error) {
+	policy, ok := r.policy(serviceName, methodName)
+	if !ok {
+		return call()
+	}
+
+	for attempt := 1; ; attempt++ {
+		out, err := call()
+		if err == nil {
+			r.succeed()
+			return out, nil
+		}
+		if !retryable(policy, err) || !r.fail() ||
+			attempt >= policy.MaxAttempts || !r.isIdempotent(serviceName, methodName) {
+			return nil, err
+		}
+
+		timer := time.NewTimer(backoff(policy.MinBackoff, policy.MaxBackoff, attempt-1))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+	}
+}
+
+func (r *retrier) isIdempotent(serviceName, methodName string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.idempotent[serviceName+"."+methodName]
+}
+
+func (r *retrier) succeed() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens += r.tokenRatio
+	if r.tokens > r.maxTokens {
+		r.tokens = r.maxTokens
+	}
+}
+
+// 返回还能不能重试
+func (r *retrier) fail() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens--
+	if r.tokens < 0 {
+		r.tokens = 0
+	}
+	return r.tokens > r.maxTokens/2
+}
+
+func retryable(policy RetryPolicy, err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrShutdown) {
+		return false
+	}
+	var code Code
+	var e *Error
+	var ne net.Error
+	switch {
+	case errors.As(err, &e):
+		code = e.Code
+	case errors.As(err, &ne), errors.Is(err, ErrReconnecting), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		code = CodeUnavailable
+	default:
+		return false
+	}
+
+	codes := policy.Codes
+	if len(codes) == 0 {
+		codes = []Code{CodeUnavailable}
+	}
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package rpc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 前failures次请求返回503
+type flakyHandler struct {
+	next     http.Handler
+	failures atomic.Int32
+	requests atomic.Int32
+}
+
+func (h *flakyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.requests.Add(1)
+	if h.failures.Add(-1) >= 0 {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	h.next.ServeHTTP(w, r)
+}
+
+func newFlakyServer(t *testing.T) (*flakyHandler, string) {
+	server := NewServer()
+	server.Register(new(Userservice), "UserService")
+	server.MarkIdempotent("UserService", "GetUserById")
+	h := &flakyHandler{next: server}
+	ts := httptest.NewServer(h)
+	t.Cleanup(ts.Close)
+	return h, ts.URL + "/rpc"
+}
+
+func TestRetryIdempotent(t *testing.T) {
+	h, url := newFlakyServer(t)
+	client, _ := DialHTTP(url, WithRetryPolicy("UserService", RetryPolicy{
+		MaxAttempts: 3,
+		MinBackoff:  time.Millisecond,
+		MaxBackoff:  10 * time.Millisecond,
+	}))
+
+	// 还不知道是不是幂等的，不重试
+	h.failures.Store(1)
+	if _, err := client.Call("UserService", "GetUserById", []interface{}{1}); ErrorCode(err) != CodeUnavailable {
+		t.Fatal(err)
+	}
+	if _, err := client.Call("UserService", "GetUserById", []interface{}{1}); err != nil {
+		t.Fatal(err)
+	}
+
+	h.failures.Store(2)
+	h.requests.Store(0)
+	if _, err := client.Call("UserService", "GetUserById", []interface{}{1}); err != nil {
+		t.Error(err)
+	}
+	if n := h.requests.Load(); n != 3 {
+		t.Error(n)
+	}
+
+	// 超过MaxAttempts
+	h.failures.Store(3)
+	h.requests.Store(0)
+	if _, err := client.Call("UserService", "GetUserById", []interface{}{1}); ErrorCode(err) != CodeUnavailable {
+		t.Error(err)
+	}
+	if n := h.requests.Load(); n != 3 {
+		t.Error(n)
+	}
+}
+
+func TestRetryNotIdempotent(t *testing.T) {
+	h, url := newFlakyServer(t)
+	client, _ := DialHTTP(url, WithRetryPolicy("", RetryPolicy{MaxAttempts: 3}))
+
+	if _, err := client.Call("UserService", "Add", []interface{}{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	h.failures.Store(1)
+	h.requests.Store(0)
+	if _, err := client.Call("UserService", "Add", []interface{}{1, 2}); ErrorCode(err) != CodeUnavailable {
+		t.Error(err)
+	}
+	if n := h.requests.Load(); n != 1 {
+		t.Error(n)
+	}
+}
+
+func TestRetryCodes(t *testing.T) {
+	h, url := newFlakyServer(t)
+	client, _ := DialHTTP(url, WithRetryPolicy("UserService.GetUserById", RetryPolicy{
+		MaxAttempts: 3,
+		Codes:       []Code{CodeInternal},
+	}))
+
+	client.Call("UserService", "GetUserById", []interface{}{1})
+	h.failures.Store(1)
+	h.requests.Store(0)
+	if _, err := client.Call("UserService", "GetUserById", []interface{}{1}); ErrorCode(err) != CodeUnavailable {
+		t.Error(err)
+	}
+	if n := h.requests.Load(); n != 1 {
+		t.Error(n)
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	h, url := newFlakyServer(t)
+	client, _ := DialHTTP(url,
+		WithRetryPolicy("", RetryPolicy{MaxAttempts: 5}),
+		WithRetryBudget(4, 0.5))
+
+	client.Call("UserService", "GetUserById", []interface{}{1})
+
+	// 令牌4 -> 3 -> 2，到一半就不再重试
+	h.failures.Store(10)
+	h.requests.Store(0)
+	if _, err := client.Call("UserService", "GetUserById", []interface{}{1}); ErrorCode(err) != CodeUnavailable {
+		t.Error(err)
+	}
+	if n := h.requests.Load(); n != 2 {
+		t.Error(n)
+	}
+
+	// 令牌不够时连一次重试都没有
+	h.requests.Store(0)
+	client.Call("UserService", "GetUserById", []interface{}{1})
+	if n := h.requests.Load(); n != 1 {
+		t.Error(n)
+	}
+}
@@ -1,9 +1,9 @@
 package rpc
 
 import (
-	"encoding/json"
+	"context"
+	"crypto/tls"
 	"errors"
-	"io"
 	"net"
 	"reflect"
 	"sync"
@@ -16,71 +16,219 @@ type param struct {
 	InArgs      []any
 	OutArgs     []any
 	Error       string
+	Code        Code
+	// 服务端告诉客户端这个方法是不是幂等的，决定能不能重试
+	Idempotent bool
+	// 请求里是客户端的metadata，响应里是服务端的trailer
+	Metadata Metadata
+}
+
+func (p *param) setError(code Code, message string) {
+	p.Code = code
+	p.Error = message
+}
+
+func (p *param) err() error {
+	if p.Error == "" {
+		return nil
+	}
+	code := p.Code
+	if code == CodeOK {
+		code = CodeUnknown
+	}
+	return &Error{Code: code, Message: p.Error}
 }
 
 type Client struct {
-	encoder *json.Encoder
-	decoder *json.Decoder
-	conn    net.Conn
+	transport    transport
+	codec        Codec
+	retry        *retrier
+	breakers     *breakers
+	metrics      Metrics
+	tracer       Tracer
+	interceptors []ClientInterceptor
+	signer       func(call *CallInfo, md Metadata) error
+	// Pool里的连接是地址，用来区分熔断器
+	endpoint string
+}
+
+func Dial(network, address string, opts ...DialOption) (*Client, error) {
+	return dial(func() (net.Conn, error) {
+		return net.Dial(network, address)
+	}, opts)
 }
 
-func Dial(network, address string) (*Client, error) {
-	conn, err := net.Dial(network, address)
+func DialTLS(network, address string, config *tls.Config, opts ...DialOption) (*Client, error) {
+	return dial(func() (net.Conn, error) {
+		return tls.Dial(network, address, config)
+	}, opts)
+}
+
+func dial(dialer func() (net.Conn, error), opts []DialOption) (*Client, error) {
+	conn, err := dialer()
 	if err != nil {
 		return nil, err
 	}
+	o := newDialOptions(opts)
+	if !o.reconnect {
+		return NewClientWithConn(conn, opts...), nil
+	}
 	return &Client{
-		encoder: json.NewEncoder(conn),
-		decoder: json.NewDecoder(conn),
-		conn:    conn,
+		transport:    newReconnectTransport(conn, dialer, o),
+		codec:        o.codec,
+		retry:        newRetrier(o),
+		breakers:     newBreakers(o.breaker),
+		metrics:      o.metrics,
+		tracer:       o.tracer,
+		interceptors: o.interceptors,
+		signer:       o.signer,
 	}, nil
 }
 
+// 在已经建好的连接上创建客户端，比如net.Pipe、unix socket或者自己拨号的连接
+func DialConn(conn net.Conn, opts ...DialOption) *Client {
+	return NewClientWithConn(conn, opts...)
+}
+
+func NewClientWithConn(conn net.Conn, opts ...DialOption) *Client {
+	o := newDialOptions(opts)
+	return &Client{
+		transport:    newStreamTransport(conn, o),
+		codec:        o.codec,
+		retry:        newRetrier(o),
+		breakers:     newBreakers(o.breaker),
+		metrics:      o.metrics,
+		tracer:       o.tracer,
+		interceptors: o.interceptors,
+		signer:       o.signer,
+	}
+}
+
 func (c *Client) Call(serviceName, methodName string, inArgs []any) (*Out, error) {
-	var p param
+	return c.CallContext(context.Background(), serviceName, methodName, inArgs)
+}
 
-	for _, arg := range inArgs {
-		if reflect.TypeOf(arg).Kind() == reflect.Func {
-			return nil, errors.New("不支持函数类型")
+func (c *Client) CallContext(ctx context.Context, serviceName, methodName string, inArgs []any) (*Out, error) {
+	invoke := chainInvoker(c.interceptors, func(ctx context.Context, call *CallInfo) (*Out, error) {
+		if err := checkArgs(call.InArgs); err != nil {
+			return nil, err
 		}
-	}
+		return c.retry.do(ctx, call.ServiceName, call.MethodName, func() (*Out, error) {
+			return c.call(ctx, call)
+		})
+	})
+	return invoke(ctx, newCallInfo(ctx, serviceName, methodName, inArgs))
+}
 
-	if err := c.encoder.Encode(param{
+func newCallInfo(ctx context.Context, serviceName, methodName string, inArgs []any) *CallInfo {
+	return &CallInfo{
 		ServiceName: serviceName,
 		MethodName:  methodName,
 		InArgs:      inArgs,
-	}); err != nil {
+		Metadata:    outgoingMetadata(ctx).copy(),
+	}
+}
+
+func checkArgs(inArgs []any) error {
+	for _, arg := range inArgs {
+		if arg != nil && reflect.TypeOf(arg).Kind() == reflect.Func {
+			return errors.New("不支持函数类型")
+		}
+	}
+	return nil
+}
+
+// 一次调用，不重试
+func (c *Client) call(ctx context.Context, call *CallInfo) (out *Out, err error) {
+	var p param
+
+	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
 
-	if err := c.decoder.Decode(&p); err != nil {
+	if m := c.metrics; m != nil {
+		m.CallStarted(SideClient, call.ServiceName, call.MethodName)
+		start := time.Now()
+		defer func() {
+			m.CallFinished(SideClient, call.ServiceName, call.MethodName, ErrorCode(err), time.Since(start))
+		}()
+	}
+
+	if b := c.breakers.get(c.endpoint, call.ServiceName+"."+call.MethodName); b != nil {
+		generation, ok := b.allow()
+		if !ok {
+			return nil, ErrCircuitOpen
+		}
+		start := time.Now()
+		defer func() {
+			b.done(generation, err, time.Since(start))
+		}()
+	}
+
+	md := call.Metadata
+	if c.tracer != nil {
+		var span Span
+		ctx, span = startSpan(ctx, c.tracer, SpanKindClient, c.codec, call)
+		defer func() {
+			endSpan(span, c.codec, p.OutArgs, err)
+		}()
+	}
+	// 没有tracer时也把收到的trace传下去
+	if sc := SpanContextFromContext(ctx); sc.IsValid() {
+		md = md.copy()
+		md[traceparentKey] = []string{sc.Traceparent()}
+	}
+	if c.signer != nil {
+		md = md.copy()
+		if err := c.signer(call, md); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.transport.roundTrip(ctx, &param{
+		ServiceName: call.ServiceName,
+		MethodName:  call.MethodName,
+		InArgs:      call.InArgs,
+		Metadata:    md,
+	}, &p); err != nil {
 		return nil, err
 	}
 
-	if p.Error != "" {
-		return nil, errors.New(p.Error)
+	c.retry.learn(call.ServiceName, call.MethodName, p.Idempotent)
+	receiveTrailer(ctx, p.Metadata)
+	if err := p.err(); err != nil {
+		return nil, err
 	}
 
-	return &Out{p.OutArgs}, nil
+	return &Out{p.OutArgs, c.codec}, nil
 }
 
 func (c *Client) Close() error {
-	return c.conn.Close()
+	return c.transport.close()
 }
 
+// HTTP客户端返回nil，自动重连的客户端返回当前的连接
 func (c *Client) GetConn() net.Conn {
-	return c.conn
+	return c.transport.rawConn()
 }
 
 type Server struct {
-	services map[string]any
-	mu       *sync.Mutex
+	services     map[string]any
+	idempotent   map[string]bool
+	interceptors []Interceptor
+	limiter      *limiter
+	mu           *sync.Mutex
+	opts         serverOptions
 }
 
-func NewServer() *Server {
+func NewServer(opts ...ServerOption) *Server {
+	o := newServerOptions(opts)
 	return &Server{
-		services: make(map[string]any),
-		mu:       new(sync.Mutex),
+		services:   make(map[string]any),
+		idempotent: make(map[string]bool),
+		limiter:    newLimiter(o),
+		mu:         new(sync.Mutex),
+		opts:       o,
 	}
 }
 
@@ -90,108 +238,247 @@ func (s *Server) Register(srv any, name string) {
 	s.services[name] = srv
 }
 
+// 标记为幂等的方法，客户端在出错时才可以按重试策略重试
+func (s *Server) MarkIdempotent(serviceName string, methodNames ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, name := range methodNames {
+		s.idempotent[serviceName+"."+name] = true
+	}
+}
+
+func (s *Server) isIdempotent(serviceName, methodName string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.idempotent[serviceName+"."+methodName]
+}
+
+func (s *Server) Serve(l net.Listener) error {
+	if s.opts.tlsConfig != nil {
+		l = tls.NewListener(l, s.opts.tlsConfig)
+	}
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				continue
+			}
+			return err
+		}
+		go s.ServeConn(conn)
+	}
+}
+
 func (s *Server) ServeConn(conn net.Conn) {
+	s.serveConn(withPeer(context.Background(), newPeer(conn, s.opts.handshakeTimeout)), conn)
+}
+
+func (s *Server) serveConn(ctx context.Context, conn net.Conn) {
+	conn = meterConn(conn, s.opts.metrics, SideServer)
 	defer conn.Close()
 
-	var p param
-	decoder := json.NewDecoder(conn)
-	encoder := json.NewEncoder(conn)
+	ctx = s.connContext(ctx)
+	decoder := s.opts.codec.NewDecoder(conn)
+	encoder := s.opts.codec.NewEncoder(conn)
+
+	s.logConn(ctx, "rpc connection opened")
+	defer s.logConn(ctx, "rpc connection closed")
 
 	for {
-		if err := decoder.Decode(&p); err == io.EOF {
+		var p param
+		if err := decoder.Decode(&p); err != nil {
+			if !closedConnError(err) {
+				s.logDecodeError(ctx, err)
+			}
 			break
 		}
 
-		_, ok := s.services[p.ServiceName]
-		if !ok {
-			p.Error = "服务没找到"
-			encoder.Encode(&p)
-			continue
-		}
+		s.call(ctx, &p)
 
-		m, b := reflect.TypeOf(s.services[p.ServiceName]).MethodByName(p.MethodName)
-		if !b {
-			p.Error = "方法没找到"
-			encoder.Encode(&p)
-			continue
+		if err := encoder.Encode(&p); err != nil {
+			break
 		}
+	}
+}
 
-		mtype := m.Type
-		if len(p.InArgs) != mtype.NumIn()-1 {
-			p.Error = "参数个数不匹配"
-			encoder.Encode(&p)
-			continue
+func (s *Server) call(ctx context.Context, p *param) {
+	if m := s.opts.metrics; m != nil {
+		// 名字是客户端随便发的，不存在的服务和方法都算成unknown，不然指标会无限增长
+		service, method := p.ServiceName, p.MethodName
+		if _, _, err := s.lookup(service, method); err != nil {
+			service, method = unknownLabel, unknownLabel
 		}
+		m.CallStarted(SideServer, service, method)
+		start := time.Now()
+		defer func() {
+			m.CallFinished(SideServer, service, method, p.Code, time.Since(start))
+		}()
+	}
 
-		inValues, matched := s.match(p, mtype)
-		if !matched {
-			p.Error = "参数类型不匹配"
-			encoder.Encode(&p)
-			continue
-		}
+	p.OutArgs = nil
+	p.Idempotent = s.isIdempotent(p.ServiceName, p.MethodName)
 
-		outValues := reflect.ValueOf(s.services[p.ServiceName]).MethodByName(p.MethodName).Call(inValues)
-		for _, v := range outValues {
-			p.OutArgs = append(p.OutArgs, v.Interface())
-		}
+	call := &CallInfo{
+		ServiceName: p.ServiceName,
+		MethodName:  p.MethodName,
+		InArgs:      p.InArgs,
+		Metadata:    p.Metadata.lower(),
+	}
+	if call.Metadata == nil {
+		call.Metadata = Metadata{}
+	}
+	call.Peer, _ = PeerFromContext(ctx)
+	if sc, err := ParseTraceparent(call.Metadata.Get(traceparentKey)); err == nil {
+		ctx = ContextWithSpanContext(ctx, sc)
+	}
+	if s.opts.logger != nil {
+		start := time.Now()
+		defer func(ctx context.Context) {
+			s.logCall(ctx, p, call.InArgs, time.Since(start))
+		}(ctx)
+	}
+
+	ctx, trailer := withTrailer(withIncomingMetadata(ctx, call.Metadata))
+	outArgs, err := s.handler()(ctx, call)
+	p.Metadata = trailer.get()
+	if err != nil {
+		p.setError(ErrorCode(err), err.Error())
+		return
+	}
+	p.OutArgs = outArgs
+}
+
+// 方法的第一个参数可以是context.Context，里面有对端的信息，不算在InArgs里
+func (s *Server) invoke(ctx context.Context, call *CallInfo) (outArgs []any, err error) {
+	if s.opts.tracer != nil {
+		var span Span
+		ctx, span = startSpan(ctx, s.opts.tracer, SpanKindServer, s.opts.codec, call)
+		defer func() {
+			endSpan(span, s.opts.codec, outArgs, err)
+		}()
+	}
+
+	srv, m, rpcErr := s.lookup(call.ServiceName, call.MethodName)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
 
-		encoder.Encode(&p)
+	mtype := m.Type
+	offset := argOffset(mtype)
+	if len(call.InArgs) != mtype.NumIn()-offset {
+		return nil, &Error{CodeInvalidArgument, "参数个数不匹配"}
 	}
+
+	inValues, matched := s.match(call.InArgs, mtype, offset)
+	if !matched {
+		return nil, &Error{CodeInvalidArgument, "参数类型不匹配"}
+	}
+	if offset == 2 {
+		// 拦截器可能换了metadata
+		ctx = withIncomingMetadata(ctx, call.Metadata)
+		inValues = append([]reflect.Value{reflect.ValueOf(ctx)}, inValues...)
+	}
+
+	outValues := reflect.ValueOf(srv).Method(m.Index).Call(inValues)
+	// 最后一个返回值是error并且不是nil时当作调用失败，*Error的错误码原样返回
+	if n := len(outValues); n > 0 && mtype.Out(n-1) == errorType && !outValues[n-1].IsNil() {
+		e := outValues[n-1].Interface().(error)
+		return nil, &Error{ErrorCode(e), e.Error()}
+	}
+	for _, v := range outValues {
+		outArgs = append(outArgs, v.Interface())
+	}
+	return outArgs, nil
+}
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+func argOffset(mtype reflect.Type) int {
+	if mtype.NumIn() > 1 && mtype.In(1) == contextType {
+		return 2
+	}
+	return 1
+}
+
+func (s *Server) lookup(serviceName, methodName string) (any, reflect.Method, *Error) {
+	s.mu.Lock()
+	srv, ok := s.services[serviceName]
+	s.mu.Unlock()
+	if !ok {
+		return nil, reflect.Method{}, &Error{CodeNotFound, "服务没找到"}
+	}
+
+	m, ok := reflect.TypeOf(srv).MethodByName(methodName)
+	if !ok {
+		return nil, reflect.Method{}, &Error{CodeNotFound, "方法没找到"}
+	}
+	return srv, m, nil
 }
 
-func (s *Server) match(p param, mtype reflect.Type) ([]reflect.Value, bool) {
+func (s *Server) match(inArgs []any, mtype reflect.Type, offset int) ([]reflect.Value, bool) {
 	var inValues []reflect.Value
-	for i, arg := range p.InArgs {
-		t := mtype.In(i + 1)
+	for i, arg := range inArgs {
+		t := mtype.In(i + offset)
 		if t == reflect.TypeOf(&time.Time{}) {
-			v, err := time.Parse(time.RFC3339, arg.(string))
+			str, ok := arg.(string)
+			if !ok {
+				return nil, false
+			}
+			v, err := time.Parse(time.RFC3339, str)
 			if err != nil {
 				return nil, false
 			}
 			inValues = append(inValues, reflect.ValueOf(&v))
 		} else if t == reflect.TypeOf(time.Time{}) {
-			v, err := time.Parse(time.RFC3339, arg.(string))
+			str, ok := arg.(string)
+			if !ok {
+				return nil, false
+			}
+			v, err := time.Parse(time.RFC3339, str)
 			if err != nil {
 				return nil, false
 			}
 			inValues = append(inValues, reflect.ValueOf(v))
 		} else if t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct {
 			v := reflect.New(t.Elem())
-			if !s.mapToStruct(arg.(map[string]any), v.Elem()) {
+			if !s.mapToStruct(arg, v.Elem()) {
 				return nil, false
 			}
 			inValues = append(inValues, v)
 		} else if t.Kind() == reflect.Struct {
 			v := reflect.New(t)
-			if !s.mapToStruct(arg.(map[string]any), v.Elem()) {
+			if !s.mapToStruct(arg, v.Elem()) {
 				return nil, false
 			}
 			inValues = append(inValues, v.Elem())
 		} else if t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Slice {
 			v := reflect.New(reflect.SliceOf(t.Elem().Elem()))
-			if !s.copySlice(arg.([]any), v.Elem(), t.Elem().Elem()) {
+			if !s.copySlice(arg, v.Elem(), t.Elem().Elem()) {
 				return nil, false
 			}
 			inValues = append(inValues, v)
 		} else if t.Kind() == reflect.Slice {
 			v := reflect.New(reflect.SliceOf(t.Elem()))
-			if !s.copySlice(arg.([]any), v.Elem(), t.Elem()) {
+			if !s.copySlice(arg, v.Elem(), t.Elem()) {
 				return nil, false
 			}
 			inValues = append(inValues, v.Elem())
 		} else if t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Array {
 			v := reflect.New(reflect.ArrayOf(t.Elem().Len(), t.Elem().Elem()))
-			if !s.copyArray(arg.([]any), v.Elem(), t.Elem().Elem()) {
+			if !s.copyArray(arg, v.Elem(), t.Elem().Elem()) {
 				return nil, false
 			}
 			inValues = append(inValues, v)
 		} else if t.Kind() == reflect.Array {
 			v := reflect.New(reflect.ArrayOf(t.Len(), t.Elem()))
-			if !s.copyArray(arg.([]any), v.Elem(), t.Elem()) {
+			if !s.copyArray(arg, v.Elem(), t.Elem()) {
 				return nil, false
 			}
 			inValues = append(inValues, v.Elem())
-		} else if reflect.ValueOf(arg).Type().ConvertibleTo(t) {
+		} else if arg != nil && reflect.ValueOf(arg).Type().ConvertibleTo(t) {
 			inValues = append(inValues, reflect.ValueOf(arg).Convert(t))
 		} else {
 			return nil, false
@@ -200,45 +487,38 @@ func (s *Server) match(p param, mtype reflect.Type) ([]reflect.Value, bool) {
 	return inValues, true
 }
 
-func (s *Server) mapToStruct(arg map[string]any, v reflect.Value) bool {
+// 参数的形状不对时返回false，比如结构体传成了数字
+func (s *Server) mapToStruct(value any, v reflect.Value) bool {
+	arg, ok := value.(map[string]any)
+	if !ok {
+		return false
+	}
 	for key, value := range arg {
 		structFieldValue := v.FieldByName(key)
-		if !structFieldValue.IsValid() {
+		if !structFieldValue.IsValid() || !structFieldValue.CanSet() {
 			return false
 		}
 		if structFieldValue.Kind() == reflect.Struct {
-			if !s.mapToStruct(value.(map[string]any), structFieldValue) {
+			if !s.mapToStruct(value, structFieldValue) {
 				return false
 			}
 		} else if structFieldValue.Kind() == reflect.Slice {
-			if value == nil {
-				value = make([]any, 0)
-			}
-			if !s.copySlice(value.([]any), structFieldValue, structFieldValue.Type().Elem()) {
+			if !s.copySlice(value, structFieldValue, structFieldValue.Type().Elem()) {
 				return false
 			}
 		} else if structFieldValue.Kind() == reflect.Array {
-			if value == nil {
-				value = make([]any, 0)
-			}
-			if !s.copyArray(value.([]any), structFieldValue, structFieldValue.Type().Elem()) {
+			if !s.copyArray(value, structFieldValue, structFieldValue.Type().Elem()) {
 				return false
 			}
 		} else if structFieldValue.Kind() == reflect.Ptr && structFieldValue.Type().Elem().Kind() == reflect.Slice {
-			if value == nil {
-				value = make([]any, 0)
-			}
 			vv := reflect.New(reflect.SliceOf(structFieldValue.Type().Elem().Elem()))
-			if !s.copySlice(value.([]any), vv.Elem(), structFieldValue.Type().Elem().Elem()) {
+			if !s.copySlice(value, vv.Elem(), structFieldValue.Type().Elem().Elem()) {
 				return false
 			}
 			structFieldValue.Set(vv)
 		} else if structFieldValue.Kind() == reflect.Ptr && structFieldValue.Type().Elem().Kind() == reflect.Array {
-			if value == nil {
-				value = make([]any, 0)
-			}
 			vv := reflect.New(reflect.ArrayOf(structFieldValue.Type().Elem().Len(), structFieldValue.Type().Elem().Elem()))
-			if !s.copyArray(value.([]any), vv.Elem(), structFieldValue.Type().Elem().Elem()) {
+			if !s.copyArray(value, vv.Elem(), structFieldValue.Type().Elem().Elem()) {
 				return false
 			}
 			structFieldValue.Set(vv)
@@ -251,17 +531,22 @@ func (s *Server) mapToStruct(arg map[string]any, v reflect.Value) bool {
 	return true
 }
 
-func (s *Server) copySlice(arg []any, v reflect.Value, t reflect.Type) bool {
+// nil当作空的数组
+func (s *Server) copySlice(value any, v reflect.Value, t reflect.Type) bool {
+	arg, ok := value.([]any)
+	if !ok && value != nil {
+		return false
+	}
 	for _, value := range arg {
 		if t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct {
 			vv := reflect.New(t.Elem())
-			if !s.mapToStruct(value.(map[string]any), vv.Elem()) {
+			if !s.mapToStruct(value, vv.Elem()) {
 				return false
 			}
 			v.Set(reflect.Append(v, vv))
 		} else if t.Kind() == reflect.Struct {
 			vv := reflect.New(t)
-			if !s.mapToStruct(value.(map[string]any), vv.Elem()) {
+			if !s.mapToStruct(value, vv.Elem()) {
 				return false
 			}
 			v.Set(reflect.Append(v, vv.Elem()))
@@ -274,17 +559,21 @@ func (s *Server) copySlice(arg []any, v reflect.Value, t reflect.Type) bool {
 	return true
 }
 
-func (s *Server) copyArray(arg []any, v reflect.Value, t reflect.Type) bool {
+func (s *Server) copyArray(value any, v reflect.Value, t reflect.Type) bool {
+	arg, ok := value.([]any)
+	if !ok && value != nil || len(arg) > v.Len() {
+		return false
+	}
 	for i, value := range arg {
 		if t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct {
 			vv := reflect.New(t.Elem())
-			if !s.mapToStruct(value.(map[string]any), vv.Elem()) {
+			if !s.mapToStruct(value, vv.Elem()) {
 				return false
 			}
 			v.Index(i).Set(vv)
 		} else if t.Kind() == reflect.Struct {
 			vv := reflect.New(t)
-			if !s.mapToStruct(value.(map[string]any), vv.Elem()) {
+			if !s.mapToStruct(value, vv.Elem()) {
 				return false
 			}
 			v.Index(i).Set(vv.Elem())
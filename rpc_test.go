@@ -1,6 +1,7 @@
 package rpc
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net"
@@ -122,6 +123,14 @@ func (s *Userservice) EmptyOut(name string) {
 func (s *Userservice) EmptyInAndOut() {
 }
 
+func (s *Userservice) DivMod(a int, b int) (int, int) {
+	return a / b, a % b
+}
+
+func (s *Userservice) Sleep(ms int) {
+	time.Sleep(time.Duration(ms) * time.Millisecond)
+}
+
 func TestGetUserById(t *testing.T) {
 	server := NewServer()
 	server.Register(new(Userservice), "UserService")
@@ -762,3 +771,38 @@ func TestConcurrency(t *testing.T) {
 
 	l.Close()
 }
+
+func TestCallContextTimeout(t *testing.T) {
+	server := NewServer()
+	server.Register(new(Userservice), "UserService")
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go server.ServeConn(conn)
+		}
+	}()
+
+	client, _ := Dial("tcp", l.Addr().String())
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	out, err := client.CallContext(ctx, "UserService", "Sleep", []interface{}{500})
+	if err != context.DeadlineExceeded {
+		t.Error(out, err)
+	}
+	t.Log(err)
+
+	// 超时以后连接关掉了，后面的调用返回ErrShutdown
+	if _, err := client.Call("UserService", "Add", []interface{}{1, 2}); err != ErrShutdown {
+		t.Error(err)
+	}
+
+	client.Close()
+	if _, err := client.Call("UserService", "Add", []interface{}{1, 2}); err != ErrShutdown {
+		t.Error(err)
+	}
+	l.Close()
+}
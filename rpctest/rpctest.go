@@ -0,0 +1,15 @@
+// rpctest提供测试用的工具，不需要监听端口
+package rpctest
+
+import (
+	"net"
+
+	"github.com/guobinqiu/rpc"
+)
+
+// 用net.Pipe把客户端和服务端接起来，关掉客户端服务端也会退出
+func NewPair(server *rpc.Server, opts ...rpc.DialOption) *rpc.Client {
+	serverConn, clientConn := net.Pipe()
+	go server.ServeConn(serverConn)
+	return rpc.DialConn(clientConn, opts...)
+}
@@ -0,0 +1,55 @@
+package rpctest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/guobinqiu/rpc"
+)
+
+type Calc struct{}
+
+func (c *Calc) Add(a, b int) int {
+	return a + b
+}
+
+func (c *Calc) Sleep(ms int) {
+	time.Sleep(time.Duration(ms) * time.Millisecond)
+}
+
+func TestNewPair(t *testing.T) {
+	server := rpc.NewServer()
+	server.Register(new(Calc), "Calc")
+
+	client := NewPair(server)
+	defer client.Close()
+
+	sum, err := rpc.Call1[int](context.Background(), client, "Calc", "Add", 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum != 3 {
+		t.Error(sum)
+	}
+
+	_, err = client.Call("Calc", "Sub", []interface{}{1, 2})
+	if rpc.ErrorCode(err) != rpc.CodeNotFound {
+		t.Error(err)
+	}
+}
+
+func TestNewPairTimeout(t *testing.T) {
+	server := rpc.NewServer()
+	server.Register(new(Calc), "Calc")
+
+	client := NewPair(server)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := client.CallContext(ctx, "Calc", "Sleep", []interface{}{200})
+	if err != context.DeadlineExceeded {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,257 @@
+package rpc
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+type JSONSchema struct {
+	Ref                  string                 `json:"$ref,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	PrefixItems          []*JSONSchema          `json:"prefixItems,omitempty"`
+	MinItems             *int                   `json:"minItems,omitempty"`
+	MaxItems             *int                   `json:"maxItems,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	AdditionalProperties any                    `json:"additionalProperties,omitempty"`
+}
+
+type MethodSchema struct {
+	Name   string      `json:"name"`
+	Input  *JSONSchema `json:"input"`
+	Output *JSONSchema `json:"output"`
+}
+
+type ServiceSchema struct {
+	Schema  string                 `json:"$schema"`
+	Name    string                 `json:"name"`
+	Methods []*MethodSchema        `json:"methods"`
+	Defs    map[string]*JSONSchema `json:"$defs,omitempty"`
+}
+
+func (s *Server) serviceNames() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.services))
+	for name := range s.services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *Server) Schemas() []*ServiceSchema {
+	var schemas []*ServiceSchema
+	for _, name := range s.serviceNames() {
+		if schema, err := s.ServiceSchema(name); err == nil {
+			schemas = append(schemas, schema)
+		}
+	}
+	return schemas
+}
+
+func (s *Server) ServiceSchema(name string) (*ServiceSchema, error) {
+	s.mu.Lock()
+	srv, ok := s.services[name]
+	s.mu.Unlock()
+	if !ok {
+		return nil, errors.New("服务没找到")
+	}
+
+	b := newSchemaBuilder("#/$defs/")
+	schema := &ServiceSchema{
+		Schema:  jsonSchemaDraft,
+		Name:    name,
+		Methods: b.methods(srv),
+	}
+
+	if len(b.defs) > 0 {
+		schema.Defs = b.defs
+	}
+	return schema, nil
+}
+
+// 含有函数、channel等无法编码的参数的方法不会出现在schema里
+func callable(mtype reflect.Type) bool {
+	for i := argOffset(mtype); i < mtype.NumIn(); i++ {
+		if !encodable(mtype.In(i), make(map[reflect.Type]bool)) {
+			return false
+		}
+	}
+	for i := 0; i < mtype.NumOut(); i++ {
+		if !encodable(mtype.Out(i), make(map[reflect.Type]bool)) {
+			return false
+		}
+	}
+	return true
+}
+
+func encodable(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return true
+	}
+	seen[t] = true
+
+	switch t.Kind() {
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer, reflect.Complex64, reflect.Complex128:
+		return false
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return encodable(t.Elem(), seen)
+	case reflect.Map:
+		return encodable(t.Key(), seen) && encodable(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).IsExported() && !encodable(t.Field(i).Type, seen) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+type schemaBuilder struct {
+	defs      map[string]*JSONSchema
+	refPrefix string
+}
+
+func newSchemaBuilder(refPrefix string) *schemaBuilder {
+	return &schemaBuilder{
+		defs:      make(map[string]*JSONSchema),
+		refPrefix: refPrefix,
+	}
+}
+
+func (b *schemaBuilder) methods(srv any) []*MethodSchema {
+	methods := []*MethodSchema{}
+	t := reflect.TypeOf(srv)
+	for i := 0; i < t.NumMethod(); i++ {
+		m := t.Method(i)
+		if !callable(m.Type) {
+			continue
+		}
+
+		var in, out []reflect.Type
+		for j := argOffset(m.Type); j < m.Type.NumIn(); j++ {
+			in = append(in, m.Type.In(j))
+		}
+		for j := 0; j < m.Type.NumOut(); j++ {
+			out = append(out, m.Type.Out(j))
+		}
+
+		methods = append(methods, &MethodSchema{
+			Name:   m.Name,
+			Input:  b.tuple(in, false),
+			Output: b.tuple(out, true),
+		})
+	}
+	return methods
+}
+
+func (b *schemaBuilder) tuple(types []reflect.Type, output bool) *JSONSchema {
+	n := len(types)
+	schema := &JSONSchema{
+		Type:     "array",
+		MinItems: &n,
+		MaxItems: &n,
+	}
+	for _, t := range types {
+		schema.PrefixItems = append(schema.PrefixItems, b.schema(t, output))
+	}
+	return schema
+}
+
+// 入参由服务端按字段名赋值，返回值由json编码，所以返回值需要遵守json tag
+func (b *schemaBuilder) schema(t reflect.Type, output bool) *JSONSchema {
+	if t == timeType {
+		return &JSONSchema{Type: "string", Format: "date-time"}
+	}
+	if output && t.Implements(marshalerType) {
+		return &JSONSchema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+	case reflect.Ptr:
+		return b.schema(t.Elem(), output)
+	case reflect.Slice:
+		return &JSONSchema{Type: "array", Items: b.schema(t.Elem(), output)}
+	case reflect.Array:
+		n := t.Len()
+		return &JSONSchema{Type: "array", Items: b.schema(t.Elem(), output), MinItems: &n, MaxItems: &n}
+	case reflect.Map:
+		return &JSONSchema{Type: "object", AdditionalProperties: b.schema(t.Elem(), output)}
+	case reflect.Struct:
+		return b.structRef(t, output)
+	}
+	return &JSONSchema{}
+}
+
+func (b *schemaBuilder) structRef(t reflect.Type, output bool) *JSONSchema {
+	key := t.String()
+	if output && hasJSONTags(t) {
+		key += ".output"
+	}
+	ref := &JSONSchema{Ref: b.refPrefix + escapeJSONPointer(key)}
+	if _, ok := b.defs[key]; ok {
+		return ref
+	}
+
+	def := &JSONSchema{
+		Type:                 "object",
+		Properties:           make(map[string]*JSONSchema),
+		AdditionalProperties: false,
+	}
+	b.defs[key] = def
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := f.Name
+		if output {
+			tag := strings.Split(f.Tag.Get("json"), ",")[0]
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+		def.Properties[name] = b.schema(f.Type, output)
+	}
+	return ref
+}
+
+func hasJSONTags(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if _, ok := t.Field(i).Tag.Lookup("json"); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func escapeJSONPointer(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
@@ -0,0 +1,79 @@
+package rpc
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func findMethodSchema(schema *ServiceSchema, name string) *MethodSchema {
+	for _, m := range schema.Methods {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+func TestServiceSchema(t *testing.T) {
+	server := NewServer()
+	server.Register(new(Userservice), "UserService")
+
+	schema, err := server.ServiceSchema("UserService")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	add := findMethodSchema(schema, "Add")
+	if add == nil {
+		t.Fatal("Add not found")
+	}
+	if len(add.Input.PrefixItems) != 2 || add.Input.PrefixItems[0].Type != "integer" {
+		t.Error(add.Input)
+	}
+	if len(add.Output.PrefixItems) != 1 || add.Output.PrefixItems[0].Type != "integer" {
+		t.Error(add.Output)
+	}
+
+	get := findMethodSchema(schema, "GetUserById")
+	if get == nil || get.Output.PrefixItems[0].Ref != "#/$defs/rpc.user" {
+		t.Error(get)
+	}
+
+	u, ok := schema.Defs["rpc.user"]
+	if !ok {
+		t.Fatal(schema.Defs)
+	}
+	if u.Properties["Address"].Ref != "#/$defs/rpc.address" {
+		t.Error(u.Properties["Address"])
+	}
+	if arr := u.Properties["HobbiesArr"]; arr.Type != "array" || *arr.MinItems != 3 || *arr.MaxItems != 3 {
+		t.Error(arr)
+	}
+
+	tt := findMethodSchema(schema, "TestTime")
+	if tt == nil || tt.Input.PrefixItems[0].Format != "date-time" {
+		t.Error(tt)
+	}
+
+	if findMethodSchema(schema, "TestFunc") != nil {
+		t.Error("TestFunc should not be in schema")
+	}
+
+	b, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		t.Error(err)
+	}
+	t.Log(string(b))
+}
+
+func TestServiceSchemaNotFound(t *testing.T) {
+	server := NewServer()
+	server.Register(new(Userservice), "UserService")
+
+	if _, err := server.ServiceSchema("UserServicee"); err == nil {
+		t.Error("expected error")
+	}
+	if len(server.Schemas()) != 1 {
+		t.Error(server.Schemas())
+	}
+}
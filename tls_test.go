@@ -0,0 +1,228 @@
+package rpc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type Whoami struct{}
+
+func (w *Whoami) Identity(ctx context.Context) string {
+	p, ok := PeerFromContext(ctx)
+	if !ok {
+		return ""
+	}
+	return p.Identity
+}
+
+func (w *Whoami) Hello(ctx context.Context, name string) string {
+	p, _ := PeerFromContext(ctx)
+	return "hello " + name + " from " + p.Identity
+}
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{cert: cert, key: key, pool: pool}
+}
+
+func (ca *testCA) issue(t *testing.T, commonName string, usage x509.ExtKeyUsage) tls.Certificate {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestMutualTLS(t *testing.T) {
+	ca := newTestCA(t)
+	server := NewServer(WithServerTLS(&tls.Config{
+		Certificates: []tls.Certificate{ca.issue(t, "server", x509.ExtKeyUsageServerAuth)},
+		ClientCAs:    ca.pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}))
+	server.Register(new(Whoami), "Whoami")
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	go server.Serve(l)
+
+	client, err := DialTLS("tcp", l.Addr().String(), &tls.Config{
+		RootCAs:      ca.pool,
+		Certificates: []tls.Certificate{ca.issue(t, "client-1", x509.ExtKeyUsageClientAuth)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := client.Call("Whoami", "Identity", []interface{}{})
+	if err != nil {
+		t.Error(err)
+	}
+	if out.Get(0) != "client-1" {
+		t.Error(out.Get(0))
+	}
+
+	out, err = client.Call("Whoami", "Hello", []interface{}{"guobin"})
+	if err != nil {
+		t.Error(err)
+	}
+	if out.Get(0) != "hello guobin from client-1" {
+		t.Error(out.Get(0))
+	}
+
+	_, err = client.Call("Whoami", "Hello", []interface{}{})
+	if ErrorCode(err) != CodeInvalidArgument {
+		t.Error(err)
+	}
+	client.Close()
+
+	// 没有客户端证书
+	client, err = DialTLS("tcp", l.Addr().String(), &tls.Config{RootCAs: ca.pool})
+	if err == nil {
+		_, err = client.Call("Whoami", "Identity", []interface{}{})
+		client.Close()
+	}
+	if err == nil {
+		t.Error("expected error without client certificate")
+	}
+	t.Log(err)
+
+	l.Close()
+}
+
+func TestTLSWithoutClientCert(t *testing.T) {
+	ca := newTestCA(t)
+	server := NewServer(WithServerTLS(&tls.Config{
+		Certificates: []tls.Certificate{ca.issue(t, "server", x509.ExtKeyUsageServerAuth)},
+	}))
+	server.Register(new(Whoami), "Whoami")
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	go server.Serve(l)
+
+	client, err := DialTLS("tcp", l.Addr().String(), &tls.Config{RootCAs: ca.pool})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := client.Call("Whoami", "Identity", []interface{}{})
+	if err != nil {
+		t.Error(err)
+	}
+	if out.Get(0) != "" {
+		t.Error(out.Get(0))
+	}
+
+	client.Close()
+	l.Close()
+}
+
+// 连上之后不握手的连接要被服务端关掉
+func TestTLSHandshakeTimeout(t *testing.T) {
+	ca := newTestCA(t)
+	server := NewServer(WithServerTLS(&tls.Config{
+		Certificates: []tls.Certificate{ca.issue(t, "server", x509.ExtKeyUsageServerAuth)},
+	}), WithHandshakeTimeout(50*time.Millisecond))
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer l.Close()
+	go server.Serve(l)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Error(err)
+	}
+}
+
+func TestHTTPSPeer(t *testing.T) {
+	ca := newTestCA(t)
+	server := NewServer()
+	server.Register(new(Whoami), "Whoami")
+	ts := httptest.NewUnstartedServer(http.NewServeMux())
+	ts.Config.Handler = server
+	ts.TLS = &tls.Config{
+		Certificates: []tls.Certificate{ca.issue(t, "server", x509.ExtKeyUsageServerAuth)},
+		ClientCAs:    ca.pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+	ts.StartTLS()
+	defer ts.Close()
+
+	httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      ca.pool,
+		Certificates: []tls.Certificate{ca.issue(t, "client-2", x509.ExtKeyUsageClientAuth)},
+	}}}
+	client, _ := DialHTTP(ts.URL+"/rpc", WithHTTPClient(httpClient))
+	out, err := client.Call("Whoami", "Identity", []interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Get(0) != "client-2" {
+		t.Error(out.Get(0))
+	}
+}
+
+func TestDialWebSocketTLS(t *testing.T) {
+	server := NewServer()
+	server.Register(new(Userservice), "UserService")
+	ts := httptest.NewTLSServer(server.WebSocketHandler())
+	defer ts.Close()
+
+	config := ts.Client().Transport.(*http.Transport).TLSClientConfig
+	client, err := DialWebSocket("wss"+strings.TrimPrefix(ts.URL, "https"), WithTLSConfig(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := client.Call("UserService", "Add", []interface{}{1, 2})
+	if err != nil {
+		t.Error(err)
+	}
+	if out.Get(0) != float64(3) {
+		t.Error(out.Get(0))
+	}
+	client.Close()
+}
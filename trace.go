@@ -0,0 +1,282 @@
+package rpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// W3C Trace Context的请求头，放在metadata里
+const traceparentKey = "traceparent"
+
+type TraceID [16]byte
+
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+type SpanID [8]byte
+
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+	// 从对端的traceparent解析出来的
+	Remote bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// 格式是version-traceid-spanid-flags，比如00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func ParseTraceparent(s string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || parts[0] == "00" && len(parts) != 4 {
+		return sc, errors.New("traceparent格式不对")
+	}
+	var version, flags [1]byte
+	if err := decodeHex(version[:], parts[0]); err != nil {
+		return sc, err
+	}
+	if err := decodeHex(sc.TraceID[:], parts[1]); err != nil {
+		return sc, err
+	}
+	if err := decodeHex(sc.SpanID[:], parts[2]); err != nil {
+		return sc, err
+	}
+	if err := decodeHex(flags[:], parts[3]); err != nil {
+		return sc, err
+	}
+	if !sc.IsValid() {
+		return sc, errors.New("traceparent的id不能全是0")
+	}
+	sc.Sampled = flags[0]&1 == 1
+	sc.Remote = true
+	return sc, nil
+}
+
+// 只接受小写的十六进制
+func decodeHex(dst []byte, s string) error {
+	if len(s) != 2*len(dst) || strings.ToLower(s) != s {
+		return errors.New("traceparent格式不对")
+	}
+	if _, err := hex.Decode(dst, []byte(s)); err != nil {
+		return errors.New("traceparent格式不对")
+	}
+	return nil
+}
+
+type spanContextKey struct{}
+
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	sc, _ := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc
+}
+
+type SpanKind int
+
+const (
+	SpanKindClient SpanKind = iota + 1
+	SpanKindServer
+)
+
+func (k SpanKind) String() string {
+	switch k {
+	case SpanKindClient:
+		return "client"
+	case SpanKindServer:
+		return "server"
+	}
+	return "unspecified"
+}
+
+// 可以用OpenTelemetry的Tracer实现这个接口，NewTracer是内置的简单实现
+type Tracer interface {
+	// 父span从ctx里取，返回的ctx里是新的span
+	Start(ctx context.Context, name string, kind SpanKind) (context.Context, Span)
+}
+
+type Span interface {
+	SpanContext() SpanContext
+	SetAttribute(key string, value any)
+	SetStatus(code Code, message string)
+	End()
+}
+
+// span的属性
+const (
+	AttrService      = "rpc.service"
+	AttrMethod       = "rpc.method"
+	AttrCode         = "rpc.code"
+	AttrRequestSize  = "rpc.request.size"
+	AttrResponseSize = "rpc.response.size"
+)
+
+func spanName(call *CallInfo) string {
+	return call.ServiceName + "/" + call.MethodName
+}
+
+func startSpan(ctx context.Context, tracer Tracer, kind SpanKind, codec Codec, call *CallInfo) (context.Context, Span) {
+	ctx, span := tracer.Start(ctx, spanName(call), kind)
+	span.SetAttribute(AttrService, call.ServiceName)
+	span.SetAttribute(AttrMethod, call.MethodName)
+	span.SetAttribute(AttrRequestSize, payloadSize(codec, call.InArgs))
+	return ctx, span
+}
+
+func endSpan(span Span, codec Codec, outArgs []any, err error) {
+	code := ErrorCode(err)
+	span.SetAttribute(AttrCode, int(code))
+	if err != nil {
+		span.SetStatus(code, err.Error())
+	} else {
+		span.SetAttribute(AttrResponseSize, payloadSize(codec, outArgs))
+	}
+	span.End()
+}
+
+// 参数编码以后的字节数
+func payloadSize(codec Codec, args []any) int {
+	w := &countingWriter{w: io.Discard}
+	codec.NewEncoder(w).Encode(args)
+	return int(w.n)
+}
+
+// 导出结束了的span，比如InMemoryExporter
+type SpanExporter interface {
+	ExportSpan(span *SpanData)
+}
+
+type SpanData struct {
+	Name        string
+	Kind        SpanKind
+	SpanContext SpanContext
+	Parent      SpanContext
+	StartTime   time.Time
+	EndTime     time.Time
+	Attributes  map[string]any
+	Code        Code
+	Message     string
+}
+
+type tracer struct {
+	exporter SpanExporter
+}
+
+// 没有父span时开始新的trace，父span没有采样的话也不采样，只有采样的span会导出
+func NewTracer(exporter SpanExporter) Tracer {
+	return &tracer{exporter: exporter}
+}
+
+func (t *tracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, Span) {
+	parent := SpanContextFromContext(ctx)
+	s := &span{
+		tracer: t,
+		data: SpanData{
+			Name:       name,
+			Kind:       kind,
+			Parent:     parent,
+			StartTime:  time.Now(),
+			Attributes: make(map[string]any),
+		},
+	}
+	sc := &s.data.SpanContext
+	if parent.IsValid() {
+		sc.TraceID = parent.TraceID
+		sc.Sampled = parent.Sampled
+	} else {
+		rand.Read(sc.TraceID[:])
+		sc.Sampled = true
+	}
+	rand.Read(sc.SpanID[:])
+	return ContextWithSpanContext(ctx, *sc), s
+}
+
+type span struct {
+	tracer *tracer
+
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+func (s *span) SpanContext() SpanContext {
+	return s.data.SpanContext
+}
+
+func (s *span) SetAttribute(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Attributes[key] = value
+}
+
+func (s *span) SetStatus(code Code, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Code = code
+	s.data.Message = message
+}
+
+func (s *span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.EndTime = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	if data.SpanContext.Sampled && s.tracer.exporter != nil {
+		s.tracer.exporter.ExportSpan(&data)
+	}
+}
+
+// 把span存在内存里，测试用
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []*SpanData
+}
+
+func (e *InMemoryExporter) ExportSpan(span *SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, span)
+}
+
+// 按结束的顺序
+func (e *InMemoryExporter) Spans() []*SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]*SpanData(nil), e.spans...)
+}
+
+func (e *InMemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = nil
+}
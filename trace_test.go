@@ -0,0 +1,128 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	sc, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" || !sc.Sampled || !sc.Remote {
+		t.Error(sc)
+	}
+	if s := sc.Traceparent(); s != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Error(s)
+	}
+
+	for _, s := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"0x-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	} {
+		if _, err := ParseTraceparent(s); err == nil {
+			t.Error(s)
+		}
+	}
+	// 以后的版本可以在后面加字段
+	if _, err := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTracing(t *testing.T) {
+	exporter := &InMemoryExporter{}
+	tracer := NewTracer(exporter)
+	server := NewServer(WithServerTracer(tracer))
+	server.Register(new(Userservice), "UserService")
+	client := pipeClient(server, WithTracer(tracer))
+	defer client.Close()
+
+	if _, err := client.Call("UserService", "Add", []interface{}{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exporter.Spans()
+	if len(spans) != 2 {
+		t.Fatal(len(spans))
+	}
+	srv, cli := spans[0], spans[1]
+	if srv.Kind != SpanKindServer || cli.Kind != SpanKindClient || srv.Name != "UserService/Add" || cli.Name != "UserService/Add" {
+		t.Error(srv.Kind, cli.Kind, srv.Name, cli.Name)
+	}
+	if cli.Parent.IsValid() {
+		t.Error(cli.Parent)
+	}
+	if srv.SpanContext.TraceID != cli.SpanContext.TraceID || srv.Parent.SpanID != cli.SpanContext.SpanID || !srv.Parent.Remote {
+		t.Error(srv.Parent, cli.SpanContext)
+	}
+	for _, span := range spans {
+		a := span.Attributes
+		if a[AttrService] != "UserService" || a[AttrMethod] != "Add" || a[AttrCode] != 0 {
+			t.Error(span.Kind, a)
+		}
+		// [1,2]和[3]
+		if a[AttrRequestSize] != 6 || a[AttrResponseSize] != 4 {
+			t.Error(span.Kind, a)
+		}
+	}
+}
+
+func TestTracingError(t *testing.T) {
+	exporter := &InMemoryExporter{}
+	server := NewServer(WithServerTracer(NewTracer(exporter)))
+	server.Register(new(Userservice), "UserService")
+	client := pipeClient(server, WithTracer(NewTracer(exporter)))
+	defer client.Close()
+
+	client.Call("UserService", "Missing", nil)
+	spans := exporter.Spans()
+	if len(spans) != 2 {
+		t.Fatal(len(spans))
+	}
+	for _, span := range spans {
+		if span.Code != CodeNotFound || span.Attributes[AttrCode] != int(CodeNotFound) || span.Message == "" {
+			t.Error(span.Kind, span.Code, span.Attributes)
+		}
+		if _, ok := span.Attributes[AttrResponseSize]; ok {
+			t.Error(span.Attributes)
+		}
+	}
+}
+
+func TestTracingPropagation(t *testing.T) {
+	exporter := &InMemoryExporter{}
+	server := NewServer(WithServerTracer(NewTracer(exporter)))
+	server.Register(new(Userservice), "UserService")
+	// 客户端没有tracer，只传递ctx里的trace
+	client := pipeClient(server)
+	defer client.Close()
+
+	parent, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := ContextWithSpanContext(context.Background(), parent)
+	if _, err := client.CallContext(ctx, "UserService", "Add", []interface{}{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	spans := exporter.Spans()
+	if len(spans) != 1 || spans[0].SpanContext.TraceID != parent.TraceID || spans[0].Parent.SpanID != parent.SpanID {
+		t.Fatal(spans)
+	}
+
+	// 没有采样的不导出
+	exporter.Reset()
+	parent.Sampled = false
+	ctx = ContextWithSpanContext(context.Background(), parent)
+	if _, err := client.CallContext(ctx, "UserService", "Add", []interface{}{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if spans := exporter.Spans(); len(spans) != 0 {
+		t.Error(spans)
+	}
+}
@@ -0,0 +1,108 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type transport interface {
+	roundTrip(ctx context.Context, req *param, resp *param) error
+	broken() bool
+	rawConn() net.Conn
+	close() error
+}
+
+// 同一个连接上的调用是串行的
+type streamTransport struct {
+	mu      sync.Mutex
+	raw     net.Conn
+	conn    net.Conn
+	buf     bytes.Buffer
+	encoder Encoder
+	decoder Decoder
+	failed  atomic.Bool
+}
+
+func newStreamTransport(conn net.Conn, o dialOptions) *streamTransport {
+	t := &streamTransport{raw: conn, conn: meterConn(conn, o.metrics, SideClient)}
+	t.encoder = o.codec.NewEncoder(&t.buf)
+	t.decoder = o.codec.NewDecoder(t.conn)
+	return t
+}
+
+// 先编码到缓冲区，参数编码失败的时候连接还能继续用
+func (t *streamTransport) roundTrip(ctx context.Context, req *param, resp *param) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// 连接已经关掉了，不要再返回读写关闭连接的错误
+	if t.failed.Load() {
+		return ErrShutdown
+	}
+
+	t.buf.Reset()
+	if err := t.encoder.Encode(req); err != nil {
+		return err
+	}
+
+	if ctx.Done() != nil {
+		if deadline, ok := ctx.Deadline(); ok {
+			t.conn.SetDeadline(deadline)
+		}
+		stop := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			select {
+			case <-ctx.Done():
+				t.conn.SetDeadline(time.Unix(1, 0))
+			case <-stop:
+			}
+		}()
+		defer func() {
+			close(stop)
+			<-done
+			t.conn.SetDeadline(time.Time{})
+		}()
+	}
+
+	if _, err := t.conn.Write(t.buf.Bytes()); err != nil {
+		return t.abort(ctx, err)
+	}
+	if err := t.decoder.Decode(resp); err != nil {
+		return t.abort(ctx, err)
+	}
+	return nil
+}
+
+// 编解码出错或者调用被取消后连接上的数据就乱了，只能关闭连接
+func (t *streamTransport) abort(ctx context.Context, err error) error {
+	t.failed.Store(true)
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		if _, ok := ctx.Deadline(); ok {
+			<-ctx.Done()
+		}
+	}
+	t.conn.Close()
+	if ctx.Err() == nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+func (t *streamTransport) broken() bool {
+	return t.failed.Load()
+}
+
+func (t *streamTransport) rawConn() net.Conn {
+	return t.raw
+}
+
+func (t *streamTransport) close() error {
+	t.failed.Store(true)
+	return t.conn.Close()
+}
@@ -0,0 +1,12 @@
+package rpc
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+// 以@开头的是linux的抽象socket，不在文件系统里
+func TestAbstractUnixSocket(t *testing.T) {
+	testUnix(t, fmt.Sprintf("@rpc-test-%d", os.Getpid()))
+}
@@ -0,0 +1,49 @@
+//go:build unix
+
+package rpc
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+func testUnix(t *testing.T, address string) {
+	server := NewServer()
+	server.Register(new(Userservice), "UserService")
+	server.Register(new(Whoami), "Whoami")
+	l, err := net.Listen("unix", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go server.Serve(l)
+
+	client, err := Dial("unix", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	sum, err := Call1[int](context.Background(), client, "UserService", "Add", 1, 2)
+	if err != nil {
+		t.Error(err)
+	}
+	if sum != 3 {
+		t.Error(sum)
+	}
+
+	// unix socket没有客户端证书，身份是空的
+	identity, err := Call1[string](context.Background(), client, "Whoami", "Identity")
+	if err != nil {
+		t.Error(err)
+	}
+	if identity != "" {
+		t.Error(identity)
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	testUnix(t, filepath.Join(t.TempDir(), "rpc.sock"))
+}
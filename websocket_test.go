@@ -0,0 +1,189 @@
+package rpc
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestDialWebSocket(t *testing.T) {
+	server := NewServer()
+	server.Register(new(Userservice), "UserService")
+	ts := httptest.NewServer(server.WebSocketHandler())
+	defer ts.Close()
+
+	client, err := DialWebSocket("ws" + strings.TrimPrefix(ts.URL, "http") + "/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := client.Call("UserService", "Add", []interface{}{1, 2})
+	if err != nil {
+		t.Error(err)
+	}
+	if out.Get(0) != float64(3) {
+		t.Error(out.Get(0))
+	}
+
+	// 大于125字节的消息要用扩展长度
+	nums := make([]int, 1000)
+	for i := range nums {
+		nums[i] = i
+	}
+	out, err = client.Call("UserService", "Sum", []interface{}{nums})
+	if err != nil {
+		t.Error(err)
+	}
+	if out.Get(0) != float64(499500) {
+		t.Error(out.Get(0))
+	}
+
+	_, err = client.Call("UserService", "GetUserByIdd", []interface{}{1})
+	if ErrorCode(err) != CodeNotFound {
+		t.Error(err)
+	}
+
+	client.Close()
+}
+
+func TestWebSocketFrames(t *testing.T) {
+	server := NewServer()
+	server.Register(new(Userservice), "UserService")
+	ts := httptest.NewServer(server.WebSocketHandler())
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	conn, err := net.Dial("tcp", u.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ws, err := handshakeWebSocket(conn, u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := ws.(*wsConn)
+
+	// ping要回pong，分片的消息要拼起来
+	msg := []byte(`{"ServiceName":"UserService","MethodName":"Add","InArgs":[1,2]}`)
+	c.writeFrame(wsOpPing, []byte("hi"))
+	c.writeRawFrame(false, wsOpText, msg[:10])
+	c.writeRawFrame(false, wsOpContinuation, msg[10:20])
+	c.writeRawFrame(true, wsOpContinuation, msg[20:])
+
+	fin, opcode, payload, err := c.readFrame()
+	if err != nil || !fin || opcode != wsOpPong || string(payload) != "hi" {
+		t.Error(fin, opcode, string(payload), err)
+	}
+	fin, opcode, payload, err = c.readFrame()
+	if err != nil || !fin || opcode != wsOpText || !strings.Contains(string(payload), `"OutArgs":[3]`) {
+		t.Error(fin, opcode, string(payload), err)
+	}
+
+	c.writeFrame(wsOpClose, []byte{0x03, 0xe8})
+	_, opcode, _, err = c.readFrame()
+	if err != nil || opcode != wsOpClose {
+		t.Error(opcode, err)
+	}
+}
+
+func (c *wsConn) writeRawFrame(fin bool, opcode byte, payload []byte) {
+	var b0 byte = opcode
+	if fin {
+		b0 |= 0x80
+	}
+	frame := []byte{b0, 0x80 | byte(len(payload)), 0, 0, 0, 0}
+	frame = append(frame, payload...)
+	c.Conn.Write(frame)
+}
+
+func TestWebSocketBadRequest(t *testing.T) {
+	server := NewServer()
+	ts := httptest.NewServer(server.WebSocketHandler())
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Error(res.StatusCode)
+	}
+}
+
+func TestWebSocketUnmaskedFrame(t *testing.T) {
+	server := NewServer()
+	server.Register(new(Userservice), "UserService")
+	ts := httptest.NewServer(server.WebSocketHandler())
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	conn, err := net.Dial("tcp", u.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ws, err := handshakeWebSocket(conn, u)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 客户端不带掩码，服务端应该用1002关闭
+	conn.Write([]byte{0x81, 0x02, '{', '}'})
+	br := ws.(*wsConn).br
+	header := make([]byte, 4)
+	if _, err := bufio.NewReader(br).Read(header); err != nil {
+		t.Fatal(err)
+	}
+	if header[0] != 0x88 || header[2] != 0x03 || header[3] != 0xea {
+		t.Errorf("%x", header)
+	}
+}
+
+func TestWebSocketOrigin(t *testing.T) {
+	upgrade := func(ts *httptest.Server, origin string) int {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		req.Header.Set("Origin", origin)
+		conn, err := net.Dial("tcp", req.URL.Host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		req.Write(conn)
+		res, err := http.ReadResponse(bufio.NewReader(conn), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.StatusCode
+	}
+
+	server := NewServer()
+	ts := httptest.NewServer(server.WebSocketHandler())
+	defer ts.Close()
+	if code := upgrade(ts, "http://evil.example.com"); code != http.StatusForbidden {
+		t.Error(code)
+	}
+	if code := upgrade(ts, ts.URL); code != http.StatusSwitchingProtocols {
+		t.Error(code)
+	}
+
+	server = NewServer(WithCheckOrigin(func(r *http.Request) bool {
+		return r.Header.Get("Origin") == "https://app.example.com"
+	}))
+	ts2 := httptest.NewServer(server.WebSocketHandler())
+	defer ts2.Close()
+	if code := upgrade(ts2, "https://app.example.com"); code != http.StatusSwitchingProtocols {
+		t.Error(code)
+	}
+	if code := upgrade(ts2, ts2.URL); code != http.StatusForbidden {
+		t.Error(code)
+	}
+}